@@ -0,0 +1,48 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Call is the parameters of a task call: the name of the task and the
+// variables given by the caller
+type Call struct {
+	Task string
+	Vars Vars
+
+	// setVars are the variables set with "set" by the tasks run before this
+	// call in its chain of callers and dependencies
+	setVars Vars
+}
+
+// withSetVars returns a copy of the call that also sees the given variables
+// set with "set", which take precedence over the ones it already sees
+func (c Call) withSetVars(vars Vars) Call {
+	if len(vars) == 0 {
+		return c
+	}
+	c.setVars = mergeVars(c.setVars, vars)
+	return c
+}
+
+// key returns a string that identifies a call, used to run a task with the same
+// variables only once. The variables set by other tasks aren't part of it.
+func (c Call) key() string {
+	if len(c.Vars) == 0 {
+		return c.Task
+	}
+
+	keys := make([]string, 0, len(c.Vars))
+	for k := range c.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%q=%q", k, c.Vars[k])
+	}
+	return fmt.Sprintf("%s[%s]", c.Task, strings.Join(pairs, ","))
+}
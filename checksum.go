@@ -0,0 +1,167 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	methodTimestamp = "timestamp"
+	methodChecksum  = "checksum"
+)
+
+var (
+	// StateDirPath is the directory, relative to the Taskfile, where Task
+	// persists state between runs, like the checksums of the tasks sources
+	StateDirPath = ".task"
+
+	checksumFilenameRegexp = regexp.MustCompile("[^A-Za-z0-9_.-]")
+)
+
+func (e *Executor) isUpToDateChecksum(ctx context.Context, call Call) (bool, error) {
+	t := e.Tasks[call.Task]
+
+	if len(t.Sources) == 0 {
+		return false, nil
+	}
+
+	checksum, err := e.getChecksum(call)
+	if err != nil {
+		return false, err
+	}
+	// like with timestamps, a task without any source file is never up to date
+	if checksum == "" {
+		return false, nil
+	}
+
+	b, err := ioutil.ReadFile(e.getChecksumFilePath(call))
+	if err != nil {
+		return false, nil
+	}
+	if strings.TrimSpace(string(b)) != checksum {
+		return false, nil
+	}
+
+	dir, err := e.getTaskDir(call)
+	if err != nil {
+		return false, err
+	}
+	generates, err := e.ReplaceSliceVariables(call, t.Generates)
+	if err != nil {
+		return false, err
+	}
+	include, exclude := splitPatterns(dir, generates)
+	for _, g := range include {
+		files, err := globPattern(g, exclude)
+		if err != nil || len(files) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *Executor) writeChecksum(call Call) error {
+	if len(e.Tasks[call.Task].Sources) == 0 {
+		return nil
+	}
+
+	checksum, err := e.getChecksum(call)
+	if err != nil || checksum == "" {
+		return err
+	}
+
+	path := e.getChecksumFilePath(call)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(checksum+"\n"), 0644)
+}
+
+func (e *Executor) getChecksum(call Call) (string, error) {
+	t := e.Tasks[call.Task]
+
+	dir, err := e.getTaskDir(call)
+	if err != nil {
+		return "", err
+	}
+	sources, err := e.ReplaceSliceVariables(call, t.Sources)
+	if err != nil {
+		return "", err
+	}
+	return getPatternsChecksum(dir, sources)
+}
+
+// getChecksumFilePath returns the file where the checksum of a call is saved.
+// Its name is made of the task name, to be readable, and of a hash of the call,
+// so the calls with other variables, or of tasks with a similar name, have
+// their own.
+func (e *Executor) getChecksumFilePath(call Call) string {
+	hash := sha256.Sum256([]byte(call.key()))
+	name := fmt.Sprintf("%s-%x", checksumFilenameRegexp.ReplaceAllString(call.Task, "-"), hash[:8])
+	return filepath.Join(e.Dir, StateDirPath, "checksum", name)
+}
+
+// getPatternsChecksum returns a checksum of both the names and the contents of
+// the files matched by the given patterns, or an empty string if they match no
+// file
+func getPatternsChecksum(dir string, patterns []string) (string, error) {
+	var files []string
+	include, exclude := splitPatterns(dir, patterns)
+	for _, p := range include {
+		matches, err := globPattern(p, exclude)
+		// a missing source doesn't make the task fail
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for i, f := range files {
+		if i > 0 && files[i-1] == f {
+			continue
+		}
+
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), info.Size())
+
+		if err := hashFile(h, f); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
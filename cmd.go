@@ -0,0 +1,92 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrCantUnmarshalCmd is returned for invalid command YAML, JSON or TOML
+	ErrCantUnmarshalCmd = errors.New("task: can't unmarshal cmd value")
+)
+
+// Cmd is a task command. It either runs the shell command in Cmd or, if Task
+// is set, calls another task with the given Vars. If IgnoreError is true, a
+// failure is logged but doesn't stop the task.
+type Cmd struct {
+	Cmd         string
+	Task        string
+	Vars        Vars
+	IgnoreError bool `yaml:"ignore_error" json:"ignore_error" toml:"ignore_error"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler interface
+func (c *Cmd) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cmd string
+	if err := unmarshal(&cmd); err == nil {
+		c.setString(cmd)
+		return nil
+	}
+	var cmdStruct struct {
+		Cmd         string
+		Task        string
+		Vars        Vars
+		IgnoreError bool `yaml:"ignore_error" json:"ignore_error"`
+	}
+	if err := unmarshal(&cmdStruct); err != nil {
+		return ErrCantUnmarshalCmd
+	}
+	if (cmdStruct.Cmd == "") == (cmdStruct.Task == "") {
+		return ErrCantUnmarshalCmd
+	}
+	c.Cmd = cmdStruct.Cmd
+	c.Task = cmdStruct.Task
+	c.Vars = cmdStruct.Vars
+	c.IgnoreError = cmdStruct.IgnoreError
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (c *Cmd) UnmarshalJSON(b []byte) error {
+	return c.UnmarshalYAML(func(v interface{}) error {
+		return json.Unmarshal(b, v)
+	})
+}
+
+// UnmarshalTOML implements toml.Unmarshaler interface
+func (c *Cmd) UnmarshalTOML(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		c.setString(value)
+		return nil
+	case map[string]interface{}:
+		cmd, _ := value["cmd"].(string)
+		task, _ := value["task"].(string)
+		if (cmd == "") == (task == "") {
+			return ErrCantUnmarshalCmd
+		}
+		vars, err := tomlVars(value["vars"])
+		if err != nil {
+			return err
+		}
+		ignoreError, _ := value["ignore_error"].(bool)
+		c.Cmd = cmd
+		c.Task = task
+		c.Vars = vars
+		c.IgnoreError = ignoreError
+		return nil
+	default:
+		return ErrCantUnmarshalCmd
+	}
+}
+
+// setString sets the command from its string form, where a "^" prefix means a
+// call to another task
+func (c *Cmd) setString(cmd string) {
+	if strings.HasPrefix(cmd, "^") {
+		c.Task = strings.TrimPrefix(cmd, "^")
+		return
+	}
+	c.Cmd = cmd
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-task/task"
+
+	"github.com/spf13/pflag"
+)
+
+// flagValues are the values completed for the flags that take one
+var flagValues = map[string][]string{
+	"completion": {"bash", "zsh", "fish"},
+	"graph":      {"dot", "json", "mermaid"},
+	"output":     {task.OutputInterleaved, task.OutputPrefixed, task.OutputGroup},
+}
+
+type completionError struct {
+	shell string
+}
+
+func (err *completionError) Error() string {
+	return fmt.Sprintf(`task: Unsupported shell "%s" for completion (should be "bash", "zsh" or "fish")`, err.shell)
+}
+
+// writeCompletion writes the completion script for the given shell
+func writeCompletion(w io.Writer, shell string) error {
+	var flags []*pflag.Flag
+	pflag.VisitAll(func(f *pflag.Flag) {
+		if !f.Hidden {
+			flags = append(flags, f)
+		}
+	})
+
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion(flags)
+	case "zsh":
+		script = zshCompletion(flags)
+	case "fish":
+		script = fishCompletion(flags)
+	default:
+		return &completionError{shell}
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+func bashCompletion(flags []*pflag.Flag) string {
+	var names, values bytes.Buffer
+	for _, f := range flags {
+		fmt.Fprintf(&names, " --%s", f.Name)
+		if f.Shorthand != "" {
+			fmt.Fprintf(&names, " -%s", f.Shorthand)
+		}
+		if v, ok := flagValues[f.Name]; ok {
+			fmt.Fprintf(&values, "    --%s)\n      COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n      return\n      ;;\n", f.Name, strings.Join(v, " "))
+		}
+	}
+
+	return fmt.Sprintf(`# bash completion for task
+
+_task_completion() {
+  local cur prev
+  if declare -F _get_comp_words_by_ref >/dev/null; then
+    _get_comp_words_by_ref -n : cur prev
+  else
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+  fi
+
+  case "$prev" in
+%s  esac
+
+  if [[ "$cur" == -* ]]; then
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+    return
+  fi
+
+  # the tasks are listed like "- name:<tabs>description", or "- name"
+  local tasks
+  tasks="$(task --list-all 2>/dev/null | cut -f 1 | sed -e 's/^- //' -e 's/:$//')"
+  COMPREPLY=($(compgen -W "$tasks" -- "$cur"))
+  if declare -F __ltrim_colon_completions >/dev/null; then
+    __ltrim_colon_completions "$cur"
+  fi
+}
+
+complete -F _task_completion task
+`, values.String(), strings.TrimSpace(names.String()))
+}
+
+func zshCompletion(flags []*pflag.Flag) string {
+	escape := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`).Replace
+
+	var specs bytes.Buffer
+	for _, f := range flags {
+		action := ""
+		if v, ok := flagValues[f.Name]; ok {
+			action = fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(v, " "))
+		} else if f.Value.Type() != "bool" {
+			action = fmt.Sprintf(":%s: ", f.Name)
+		}
+		fmt.Fprintf(&specs, "    '--%s[%s]%s' \\\n", f.Name, escape(f.Usage), action)
+		if f.Shorthand != "" {
+			fmt.Fprintf(&specs, "    '-%s[%s]%s' \\\n", f.Shorthand, escape(f.Usage), action)
+		}
+	}
+
+	return fmt.Sprintf(`#compdef task
+
+_task_tasks() {
+  local -a tasks
+  local name desc
+  # the tasks are listed like "- name:<tabs>description", or "- name"
+  task --list-all 2>/dev/null | while IFS=$'\t' read -r name desc; do
+    name="${name#- }"
+    [[ -n "$desc" ]] && name="${name%%:}"
+    tasks+=("${name//:/\\:}:${desc}")
+  done
+  _describe -t tasks 'task' tasks
+}
+
+_task() {
+  _arguments -s \
+%s    '*::task:_task_tasks'
+}
+
+compdef _task task
+`, specs.String())
+}
+
+func fishCompletion(flags []*pflag.Flag) string {
+	escape := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace
+
+	var specs bytes.Buffer
+	for _, f := range flags {
+		fmt.Fprintf(&specs, "complete -c task -l %s", f.Name)
+		if f.Shorthand != "" {
+			fmt.Fprintf(&specs, " -s %s", f.Shorthand)
+		}
+		if v, ok := flagValues[f.Name]; ok {
+			fmt.Fprintf(&specs, " -x -a '%s'", strings.Join(v, " "))
+		} else if f.Value.Type() != "bool" {
+			fmt.Fprint(&specs, " -r")
+		}
+		fmt.Fprintf(&specs, " -d '%s'\n", escape(f.Usage))
+	}
+
+	return fmt.Sprintf(`# fish completion for task
+
+# the tasks are listed like "- name:<tabs>description", or "- name"
+function __task_tasks
+    task --list-all 2>/dev/null | string replace -r '^- ' '' | string replace -r ':\t+' \t
+end
+
+complete -c task -f -a '(__task_tasks)'
+%s`, specs.String())
+}
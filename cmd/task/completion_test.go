@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-task/task"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buff bytes.Buffer
+		assert.NoError(t, writeCompletion(&buff, shell), shell)
+		assert.Contains(t, buff.String(), "task --list-all", shell)
+	}
+
+	err := writeCompletion(ioutil.Discard, "powershell")
+	assert.Equal(t, &completionError{"powershell"}, err)
+}
+
+func TestCompletionTasks(t *testing.T) {
+	var list bytes.Buffer
+	e := task.Executor{
+		Dir:    "../../testdata/completion",
+		Stdout: &list,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.ListTasks(true, false))
+	assert.Equal(t, 3, strings.Count(list.String(), "\n"), "one line per task")
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "task-completion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	listFile := filepath.Join(dir, "list.txt")
+	assert.NoError(t, ioutil.WriteFile(listFile, list.Bytes(), 0644))
+	var script bytes.Buffer
+	assert.NoError(t, writeCompletion(&script, "bash"))
+	scriptFile := filepath.Join(dir, "task.bash")
+	assert.NoError(t, ioutil.WriteFile(scriptFile, script.Bytes(), 0644))
+
+	complete := func(cur string) string {
+		// task is stubbed to print what ListTasks printed
+		cmd := exec.Command("bash", "-c", `
+list="$1"
+task() { cat "$list"; }
+source "$2"
+COMP_WORDS=(task "$3")
+COMP_CWORD=1
+_task_completion
+echo "${COMPREPLY[*]}"
+`, "bash", listFile, scriptFile, cur)
+		out, err := cmd.Output()
+		assert.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+
+	assert.Equal(t, "build clean db:migrate", complete(""))
+	assert.Equal(t, "db:migrate", complete("db"))
+}
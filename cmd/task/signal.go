@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// gracePeriod is how long the commands have to exit after a signal, before
+// they are killed
+const gracePeriod = 5 * time.Second
+
+// handleSignals handles SIGINT and SIGTERM by canceling the returned context,
+// which makes the commands being run be sent SIGTERM, as most don't get the
+// signals sent to the process group of task. A second signal calls kill, so
+// the commands are killed without waiting for the grace period. The returned
+// func gives the first signal received, if any.
+func handleSignals(kill func()) (context.Context, func() os.Signal) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	var (
+		mutex    sync.Mutex
+		received os.Signal
+	)
+	go func() {
+		sig := <-sigs
+		mutex.Lock()
+		received = sig
+		mutex.Unlock()
+
+		cancel()
+
+		// the signals received from then on are ignored as they are still
+		// being notified
+		<-sigs
+		kill()
+	}()
+
+	return ctx, func() os.Signal {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return received
+	}
+}
+
+// signalExitCode is the conventional exit code of a process terminated by sig
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
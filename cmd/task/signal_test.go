@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSignals(t *testing.T) {
+	killed := make(chan struct{})
+	ctx, receivedSignal := handleSignals(func() { close(killed) })
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context should be canceled by the first signal")
+	}
+	assert.Equal(t, syscall.SIGTERM, receivedSignal())
+
+	// the second one kills, and the first one gives the exit code
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	select {
+	case <-killed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second signal should kill")
+	}
+	assert.Equal(t, syscall.SIGTERM, receivedSignal())
+	assert.Equal(t, 143, signalExitCode(receivedSignal()))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/go-task/task"
 
@@ -18,7 +19,7 @@ func main() {
 	log.SetFlags(0)
 
 	pflag.Usage = func() {
-		fmt.Println(`task [target1 target2 ...]: Runs commands under targets like make.
+		fmt.Print(`task [target1 target2 ...] [VAR=value ...]: Runs commands under targets like make.
 
 Example: 'task hello' with the following 'Taskfile.yml' file will generate
 an 'output.txt' file.
@@ -39,12 +40,34 @@ hello:
 		init        bool
 		force       bool
 		watch       bool
+		dry         bool
+		graph       string
+		validate    bool
+		schema      bool
+		list        bool
+		listAll     bool
+		listJSON    bool
+		completion  string
+		output      string
+		concurrency int
+		keepGoing   bool
 	)
 
 	pflag.BoolVar(&versionFlag, "version", false, "show Task version")
 	pflag.BoolVarP(&init, "init", "i", false, "creates a new Taskfile.yml in the current folder")
 	pflag.BoolVarP(&force, "force", "f", false, "forces execution even when the task is up-to-date")
 	pflag.BoolVarP(&watch, "watch", "w", false, "enables watch of the given task")
+	pflag.BoolVarP(&list, "list", "l", false, "lists the tasks with a description")
+	pflag.BoolVar(&listAll, "list-all", false, "lists all tasks, with or without a description")
+	pflag.BoolVar(&listJSON, "json", false, "prints the task list as JSON, including the up-to-date status of each task")
+	pflag.StringVarP(&output, "output", "o", "", `sets how the output of tasks is written: "interleaved", "prefixed" or "group"`)
+	pflag.BoolVarP(&keepGoing, "keep-going", "k", false, "keeps running the tasks that don't depend on a failed one")
+	pflag.IntVarP(&concurrency, "concurrency", "C", 0, "limits the number of commands run concurrently (0 means no limit)")
+	pflag.BoolVar(&dry, "dry", false, "prints the commands that would be run, without running them")
+	pflag.BoolVar(&validate, "validate", false, "validates the Taskfile, exiting with a non-zero status if it's invalid")
+	pflag.BoolVar(&schema, "schema", false, "prints the JSON Schema of the Taskfile")
+	pflag.StringVar(&graph, "graph", "", `prints the graph of the given tasks (or of all tasks) in the given format: "dot", "json" or "mermaid"`)
+	pflag.StringVar(&completion, "completion", "", `prints the completion script for the given shell: "bash", "zsh" or "fish"`)
 	pflag.Parse()
 
 	if versionFlag {
@@ -52,6 +75,22 @@ hello:
 		return
 	}
 
+	if completion != "" {
+		if err := writeCompletion(os.Stdout, completion); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if schema {
+		b, err := task.Schema()
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+
 	if init {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -66,6 +105,12 @@ hello:
 	e := task.Executor{
 		Force: force,
 		Watch: watch,
+		Dry:   dry,
+
+		KeepGoing:   keepGoing,
+		Output:      output,
+		Concurrency: concurrency,
+		GracePeriod: gracePeriod,
 
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
@@ -75,13 +120,60 @@ hello:
 		log.Fatal(err)
 	}
 
-	args := pflag.Args()
+	args, vars := parseArgs(pflag.Args())
+	e.Vars = vars
+
+	if list || listAll {
+		if err := e.ListTasks(listAll, listJSON); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if validate {
+		if err := e.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if graph != "" {
+		if err := e.WriteGraph(os.Stdout, graph, args...); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if len(args) == 0 {
 		log.Println("task: No argument given, trying default task")
 		args = []string{"default"}
 	}
 
-	if err := e.Run(args...); err != nil {
-		log.Fatal(err)
+	ctx, receivedSignal := handleSignals(e.Kill)
+	err := e.RunContext(ctx, args...)
+	if sig := receivedSignal(); sig != nil {
+		log.Printf("task: Signal received: %v", sig)
+		os.Exit(signalExitCode(sig))
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(task.ExitCode(err))
+	}
+}
+
+// parseArgs splits the arguments in task names and variables, which are given
+// in the KEY=value form
+func parseArgs(args []string) (tasks []string, vars task.Vars) {
+	for _, a := range args {
+		if !strings.Contains(a, "=") {
+			tasks = append(tasks, a)
+			continue
+		}
+		if vars == nil {
+			vars = make(task.Vars)
+		}
+		keyVal := strings.SplitN(a, "=", 2)
+		vars[keyVal[0]] = keyVal[1]
 	}
+	return
 }
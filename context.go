@@ -0,0 +1,51 @@
+package task
+
+import (
+	"context"
+	"time"
+)
+
+type stopContextKey struct{}
+
+// withGracePeriod returns a context canceled gracePeriod after ctx is, or as
+// soon as kill is, to be given to the commands so they have time to exit by
+// themselves. The original context is kept to know when no more work should
+// be started.
+func withGracePeriod(ctx, kill context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	return cancelAfterGracePeriod(ctx, ctx, kill, gracePeriod)
+}
+
+// withFinallyGracePeriod returns the context of the finally commands of a task
+// run with ctx. They run even when ctx was stopped, but then only have
+// gracePeriod to finish, from now if it already was.
+func withFinallyGracePeriod(ctx, kill context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	return cancelAfterGracePeriod(stopContext(ctx), context.Background(), kill, gracePeriod)
+}
+
+// cancelAfterGracePeriod returns a context canceled gracePeriod after after
+// is, or as soon as kill is, having stop as stop context
+func cancelAfterGracePeriod(after, stop, kill context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithValue(kill, stopContextKey{}, stop))
+	go func() {
+		select {
+		case <-after.Done():
+		case <-graceCtx.Done():
+			return
+		}
+		select {
+		case <-time.After(gracePeriod):
+			cancel()
+		case <-graceCtx.Done():
+		}
+	}()
+	return graceCtx, cancel
+}
+
+// stopContext returns the context canceled when no more work should be
+// started, which is ctx itself if it has no grace period
+func stopContext(ctx context.Context) context.Context {
+	if stop, ok := ctx.Value(stopContextKey{}).(context.Context); ok {
+		return stop
+	}
+	return ctx
+}
@@ -1,29 +1,86 @@
 package task
 
-// HasCyclicDep checks if a task tree has any cyclic dependency
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// HasCyclicDep checks if a task tree has any cyclic dependency. Use
+// CheckCyclicDep to know which tasks form the cycle.
 func (e *Executor) HasCyclicDep() bool {
-	visits := make(map[string]struct{}, len(e.Tasks))
+	return errors.Is(e.CheckCyclicDep(), ErrCyclicDependencyDetected)
+}
+
+// CheckCyclicDep checks if the task tree has any cyclic dependency, returning
+// the chain of tasks that forms the cycle. Tasks called from commands count as
+// dependencies too. It also checks that all the tasks depended on or called
+// exist, except for the names given as templates.
+func (e *Executor) CheckCyclicDep() error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	var (
+		states = make(map[string]int, len(e.Tasks))
+		chain  []string
+	)
 
-	var checkCyclicDep func(string, *Task) bool
-	checkCyclicDep = func(name string, t *Task) bool {
-		if _, ok := visits[name]; ok {
-			return false
+	var checkCyclicDep func(string) error
+	checkCyclicDep = func(name string) error {
+		switch states[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range chain {
+				if n == name {
+					cycle := append([]string{}, chain[i:]...)
+					return &CyclicDepError{append(cycle, name)}
+				}
+			}
 		}
-		visits[name] = struct{}{}
-		defer delete(visits, name)
+		states[name] = visiting
+		chain = append(chain, name)
 
+		t := e.Tasks[name]
+		refs := make([]string, 0, len(t.Deps)+len(t.Cmds)+len(t.Finally))
 		for _, d := range t.Deps {
-			if !checkCyclicDep(d, e.Tasks[d]) {
-				return false
+			refs = append(refs, d.Task)
+		}
+		for _, c := range t.allCmds() {
+			if c.Task != "" {
+				refs = append(refs, c.Task)
+			}
+		}
+
+		for _, ref := range refs {
+			if strings.Contains(ref, "{{") {
+				continue
+			}
+			if _, ok := e.Tasks[ref]; !ok {
+				return &undefinedTaskRefError{name, ref}
+			}
+			if err := checkCyclicDep(ref); err != nil {
+				return err
 			}
 		}
-		return true
+
+		chain = chain[:len(chain)-1]
+		states[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(e.Tasks))
+	for name := range e.Tasks {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	for k, v := range e.Tasks {
-		if !checkCyclicDep(k, v) {
-			return true
+	for _, name := range names {
+		if err := checkCyclicDep(name); err != nil {
+			return err
 		}
 	}
-	return false
+	return nil
 }
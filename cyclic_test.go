@@ -1,40 +1,82 @@
 package task_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/go-task/task"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCyclicDepCheck(t *testing.T) {
 	isCyclic := &task.Executor{
 		Tasks: task.Tasks{
 			"task-a": &task.Task{
-				Deps: []string{"task-b"},
+				Deps: []*task.Dep{{Task: "task-b"}},
+			},
+			"task-b": &task.Task{
+				Deps: []*task.Dep{{Task: "task-a"}},
+			},
+		},
+	}
+
+	err := isCyclic.CheckCyclicDep()
+	if assert.Error(t, err, "Task should be cyclic") {
+		assert.Contains(t, err.Error(), "task-a -> task-b -> task-a")
+	}
+	assert.True(t, errors.Is(err, task.ErrCyclicDependencyDetected))
+	assert.True(t, isCyclic.HasCyclicDep())
+	var cyclicErr *task.CyclicDepError
+	if assert.True(t, errors.As(err, &cyclicErr)) {
+		assert.Equal(t, []string{"task-a", "task-b", "task-a"}, cyclicErr.Chain())
+	}
+
+	isCyclicByCall := &task.Executor{
+		Tasks: task.Tasks{
+			"task-a": &task.Task{
+				Deps: []*task.Dep{{Task: "task-b"}},
 			},
 			"task-b": &task.Task{
-				Deps: []string{"task-a"},
+				Cmds: []*task.Cmd{{Cmd: "echo"}, {Task: "task-c"}},
+			},
+			"task-c": &task.Task{
+				Deps: []*task.Dep{{Task: "task-b"}},
 			},
 		},
 	}
 
-	if !isCyclic.HasCyclicDep() {
-		t.Error("Task should be cyclic")
+	err = isCyclicByCall.CheckCyclicDep()
+	if assert.Error(t, err, "Task should be cyclic") {
+		assert.Contains(t, err.Error(), "task-b -> task-c -> task-b")
 	}
 
 	isNotCyclic := &task.Executor{
 		Tasks: task.Tasks{
 			"task-a": &task.Task{
-				Deps: []string{"task-c"},
+				Deps: []*task.Dep{{Task: "task-c"}},
 			},
 			"task-b": &task.Task{
-				Deps: []string{"task-c"},
+				Deps: []*task.Dep{{Task: "task-c"}},
 			},
 			"task-c": &task.Task{},
 		},
 	}
 
-	if isNotCyclic.HasCyclicDep() {
-		t.Error("Task should not be cyclic")
+	assert.NoError(t, isNotCyclic.CheckCyclicDep(), "Task should not be cyclic")
+	assert.False(t, isNotCyclic.HasCyclicDep(), "Task should not be cyclic")
+
+	hasUndefinedDep := &task.Executor{
+		Tasks: task.Tasks{
+			"task-a": &task.Task{
+				Deps: []*task.Dep{{Task: "task-b"}, {Task: "{{.TEMPLATED}}"}},
+			},
+		},
+	}
+
+	err = hasUndefinedDep.CheckCyclicDep()
+	if assert.Error(t, err, "Task should have an undefined dep") {
+		assert.Contains(t, err.Error(), `"task-b"`)
 	}
+	assert.False(t, errors.Is(err, task.ErrCyclicDependencyDetected))
 }
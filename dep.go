@@ -0,0 +1,66 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrCantUnmarshalDep is returned for invalid dep YAML, JSON or TOML
+	ErrCantUnmarshalDep = errors.New("task: can't unmarshal dep value")
+)
+
+// Dep is a task dependency
+type Dep struct {
+	Task string
+	Vars Vars
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler interface
+func (d *Dep) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var task string
+	if err := unmarshal(&task); err == nil {
+		d.Task = task
+		return nil
+	}
+	var taskCall struct {
+		Task string
+		Vars Vars
+	}
+	if err := unmarshal(&taskCall); err != nil {
+		return ErrCantUnmarshalDep
+	}
+	d.Task = taskCall.Task
+	d.Vars = taskCall.Vars
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (d *Dep) UnmarshalJSON(b []byte) error {
+	return d.UnmarshalYAML(func(v interface{}) error {
+		return json.Unmarshal(b, v)
+	})
+}
+
+// UnmarshalTOML implements toml.Unmarshaler interface
+func (d *Dep) UnmarshalTOML(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		d.Task = value
+		return nil
+	case map[string]interface{}:
+		task, ok := value["task"].(string)
+		if !ok {
+			return ErrCantUnmarshalDep
+		}
+		vars, err := tomlVars(value["vars"])
+		if err != nil {
+			return err
+		}
+		d.Task = task
+		d.Vars = vars
+		return nil
+	default:
+		return ErrCantUnmarshalDep
+	}
+}
@@ -3,46 +3,103 @@ package task
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/go-task/task/execext"
 )
 
 var (
-	// ErrCyclicDependencyDetected is returned when a cyclic dependency was found in the Taskfile
+	// ErrCyclicDependencyDetected is returned when a cyclic dependency was found in the Taskfile.
+	// The *CyclicDepError returned by CheckCyclicDep matches it with errors.Is.
 	ErrCyclicDependencyDetected = errors.New("task: cyclic dependency detected")
 	// ErrTaskfileAlreadyExists is returned on creating a Taskfile if one already exists
 	ErrTaskfileAlreadyExists = errors.New("task: A Taskfile already exists")
 )
 
-type taskFileNotFound struct {
-	taskFile string
+// TaskfileNotFoundError is returned when no Taskfile is found in any of the
+// supported formats
+type TaskfileNotFoundError struct {
+	path string
 }
 
-func (err taskFileNotFound) Error() string {
-	return fmt.Sprintf(`task: No task file found (is it named "%s"?). Use "task --init" to create a new one`, err.taskFile)
+func (err *TaskfileNotFoundError) Error() string {
+	return fmt.Sprintf(`task: No task file found (is it named "%s"?). Use "task --init" to create a new one`, err.path)
 }
 
-type taskNotFoundError struct {
+// Path returns the path of the Taskfile looked for, without extension
+func (err *TaskfileNotFoundError) Path() string {
+	return err.path
+}
+
+// TaskNotFoundError is returned when a task to be run doesn't exist
+type TaskNotFoundError struct {
 	taskName string
 }
 
-func (err *taskNotFoundError) Error() string {
+func (err *TaskNotFoundError) Error() string {
 	return fmt.Sprintf(`task: Task "%s" not found`, err.taskName)
 }
 
-type taskRunError struct {
+// TaskName returns the name of the task not found
+func (err *TaskNotFoundError) TaskName() string {
+	return err.taskName
+}
+
+// TaskRunError is returned when a command of a task fails
+type TaskRunError struct {
 	taskName string
+	cmdIndex int
 	err      error
 }
 
-func (err *taskRunError) Error() string {
+func (err *TaskRunError) Error() string {
 	return fmt.Sprintf(`task: Failed to run task "%s": %v`, err.taskName, err.err)
 }
 
-type cyclicDepError struct {
+// TaskName returns the name of the task that failed
+func (err *TaskRunError) TaskName() string {
+	return err.taskName
+}
+
+// CommandIndex returns the index of the command that failed, counting the
+// commands of the task and then its finally commands
+func (err *TaskRunError) CommandIndex() int {
+	return err.cmdIndex
+}
+
+// Unwrap returns the error of the command, like an *execext.ExitError
+func (err *TaskRunError) Unwrap() error {
+	return err.err
+}
+
+// CyclicDepError is returned when a task depends on or calls itself, directly
+// or not
+type CyclicDepError struct {
+	chain []string
+}
+
+func (err *CyclicDepError) Error() string {
+	return fmt.Sprintf(`task: Cyclic dependency detected: %s`, strings.Join(err.chain, " -> "))
+}
+
+// Is makes the error match ErrCyclicDependencyDetected with errors.Is
+func (err *CyclicDepError) Is(target error) bool {
+	return target == ErrCyclicDependencyDetected
+}
+
+// Chain returns the names of the tasks in the cycle, starting and ending with
+// the same task
+func (err *CyclicDepError) Chain() []string {
+	return err.chain
+}
+
+type undefinedTaskRefError struct {
 	taskName string
+	refName  string
 }
 
-func (err *cyclicDepError) Error() string {
-	return fmt.Sprintf(`task: Cyclic dependency of task "%s" detected`, err.taskName)
+func (err *undefinedTaskRefError) Error() string {
+	return fmt.Sprintf(`task: Task "%s" depends on or calls undefined task "%s"`, err.taskName, err.refName)
 }
 
 type cantWatchNoSourcesError struct {
@@ -52,3 +109,127 @@ type cantWatchNoSourcesError struct {
 func (err *cantWatchNoSourcesError) Error() string {
 	return fmt.Sprintf(`task: Can't watch task "%s" because it has no specified sources`, err.taskName)
 }
+
+type invalidMethodError struct {
+	taskName string
+	method   string
+}
+
+func (err *invalidMethodError) Error() string {
+	return fmt.Sprintf(`task: Invalid method "%s" for task "%s" (should be "%s" or "%s")`, err.method, err.taskName, methodTimestamp, methodChecksum)
+}
+
+type cyclicIncludeError struct {
+	taskFile string
+}
+
+func (err *cyclicIncludeError) Error() string {
+	return fmt.Sprintf(`task: Taskfile "%s" includes itself`, err.taskFile)
+}
+
+type duplicatedTaskError struct {
+	taskName string
+}
+
+func (err *duplicatedTaskError) Error() string {
+	return fmt.Sprintf(`task: Task "%s" is defined more than once`, err.taskName)
+}
+
+type unsupportedVersionError struct {
+	taskFile string
+	version  string
+}
+
+func (err *unsupportedVersionError) Error() string {
+	return fmt.Sprintf(`task: Taskfile "%s" has unsupported version "%s"`, err.taskFile, err.version)
+}
+
+type unknownGraphFormatError struct {
+	format string
+}
+
+func (err *unknownGraphFormatError) Error() string {
+	return fmt.Sprintf(`task: Unknown graph format "%s" (should be "dot", "json" or "mermaid")`, err.format)
+}
+
+type validationError struct {
+	errs []error
+}
+
+func (err *validationError) Error() string {
+	msgs := make([]string, len(err.errs))
+	for i, e := range err.errs {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("task: Taskfile is invalid:\n%s", strings.Join(msgs, "\n"))
+}
+
+type unknownKeyError struct {
+	file string
+	line int
+	path []string
+}
+
+func (err *unknownKeyError) Error() string {
+	position := err.file
+	if err.line > 0 {
+		position = fmt.Sprintf("%s:%d", err.file, err.line)
+	}
+	return fmt.Sprintf(`%s: unknown key "%s"`, position, strings.Join(err.path, "."))
+}
+
+type templateError struct {
+	taskName string
+	field    string
+	err      error
+}
+
+func (err *templateError) Error() string {
+	return fmt.Sprintf(`task: Invalid template in "%s" of task "%s": %v`, err.field, err.taskName, err.err)
+}
+
+type invalidOutputError struct {
+	output string
+}
+
+func (err *invalidOutputError) Error() string {
+	return fmt.Sprintf(`task: Invalid output mode "%s" (should be "%s", "%s" or "%s")`, err.output, OutputInterleaved, OutputPrefixed, OutputGroup)
+}
+
+type depFailedError struct {
+	taskName string
+}
+
+func (err *depFailedError) Error() string {
+	return fmt.Sprintf(`task: Task "%s" not run because a dependency failed`, err.taskName)
+}
+
+type tasksFailedError struct {
+	errs []error
+}
+
+func (err *tasksFailedError) Error() string {
+	msgs := make([]string, len(err.errs))
+	for i, e := range err.errs {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("task: %d task(s) failed:\n%s", len(err.errs), strings.Join(msgs, "\n"))
+}
+
+func (err *tasksFailedError) Unwrap() []error {
+	return err.errs
+}
+
+// ExitCode returns the exit status of the command that made a task fail with
+// err, or 1 if err wasn't caused by a command exiting with a non-zero status.
+// Wrapper scripts can then tell failures apart by the exit status of task.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *execext.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Status
+	}
+	return 1
+}
@@ -3,16 +3,26 @@ package execext
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/go-task/task/internal/interp"
 
-	"github.com/mvdan/sh/interp"
 	"github.com/mvdan/sh/syntax"
 )
 
 // RunCommandOptions is the options for the RunCommand func
 type RunCommandOptions struct {
+	// Context kills the command, and the processes it started, when done
 	Context context.Context
+	// Stop, if not nil, asks the command and the processes it started to exit
+	// when done, with SIGTERM, before Context kills them. No other command of
+	// the shell is started after it.
+	Stop context.Context
+
 	Command string
 	Dir     string
 	Env     []string
@@ -26,6 +36,15 @@ var (
 	ErrNilOptions = errors.New("execext: nil options given")
 )
 
+// ExitError is returned when a command exits with a non-zero status
+type ExitError struct {
+	Status int
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", err.Status)
+}
+
 // RunCommand runs a shell command
 func RunCommand(opts *RunCommandOptions) error {
 	if opts == nil {
@@ -37,17 +56,82 @@ func RunCommand(opts *RunCommandOptions) error {
 		return err
 	}
 
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stop := opts.Stop
+	if stop == nil {
+		stop = ctx
+	}
+
+	// the shell stops as soon as it's asked to, while the processes it runs
+	// are killed only when ctx is done
+	shellCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop.Done():
+			cancel()
+		case <-shellCtx.Done():
+		}
+	}()
+
 	r := interp.Runner{
-		Context: opts.Context,
+		Context: shellCtx,
 		File:    p,
 		Dir:     opts.Dir,
 		Env:     opts.Env,
 		Stdin:   opts.Stdin,
 		Stdout:  opts.Stdout,
 		Stderr:  opts.Stderr,
+		Exec: func(cmd *exec.Cmd) error {
+			return runProcess(ctx, stop, cmd)
+		},
 	}
 	if err = r.Run(); err != nil {
+		if status, ok := err.(interp.ExitCode); ok {
+			return &ExitError{Status: int(status)}
+		}
 		return err
 	}
 	return nil
 }
+
+// waitDelay is how long the processes left by a command that isn't in a
+// process group of its own have to close its output, once it exited
+const waitDelay = time.Second
+
+// runProcess runs cmd, in a process group of its own when it doesn't need the
+// terminal, so the processes it starts can be stopped along with it. They are
+// sent SIGTERM when stop is done and killed when ctx is.
+func runProcess(ctx, stop context.Context, cmd *exec.Cmd) error {
+	group := setProcessGroup(cmd)
+	if !group {
+		// the processes it started may be left running, holding its output
+		cmd.WaitDelay = waitDelay
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stop.Done():
+			terminateProcess(cmd, group)
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		select {
+		case <-ctx.Done():
+			killProcess(cmd, group)
+		case <-done:
+		}
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	return err
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package execext
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// setProcessGroup makes cmd run in a process group of its own, unless its
+// input is the terminal task runs in the foreground of: it must then stay in
+// the foreground process group to read from it, and to get Ctrl-C and Ctrl-Z
+// like task. It tells if it did.
+func setProcessGroup(cmd *exec.Cmd) bool {
+	if isForegroundTerminal(cmd.Stdin) {
+		return false
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return true
+}
+
+// isForegroundTerminal tells if r is a terminal whose foreground process group
+// is the one of task
+func isForegroundTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	return errno == 0 && int(pgrp) == syscall.Getpgrp()
+}
+
+// terminateProcess sends SIGTERM to the process of cmd, and to the processes
+// it started if it leads a process group
+func terminateProcess(cmd *exec.Cmd, group bool) {
+	signalProcess(cmd, syscall.SIGTERM, group)
+}
+
+func killProcess(cmd *exec.Cmd, group bool) {
+	signalProcess(cmd, syscall.SIGKILL, group)
+}
+
+func signalProcess(cmd *exec.Cmd, sig syscall.Signal, group bool) {
+	pid := cmd.Process.Pid
+	if group {
+		pid = -pid
+	}
+	_ = syscall.Kill(pid, sig)
+}
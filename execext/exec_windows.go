@@ -0,0 +1,18 @@
+package execext
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, where the processes stay attached
+// to the console so they get Ctrl-C along with task
+func setProcessGroup(cmd *exec.Cmd) bool {
+	return false
+}
+
+// terminateProcess does nothing on Windows, which has no SIGTERM
+func terminateProcess(cmd *exec.Cmd, group bool) {}
+
+func killProcess(cmd *exec.Cmd, group bool) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,4 @@
+package task
+
+// WatchDebounce exports watchDebounce to the tests
+const WatchDebounce = watchDebounce
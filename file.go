@@ -3,6 +3,7 @@ package task
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/mattn/go-zglob"
@@ -22,9 +23,9 @@ func maxTime(a, b time.Time) time.Time {
 }
 
 func getPatternsMinTime(dir string, patterns []string) (m time.Time, err error) {
-	for _, p := range patterns {
-		p = filepath.Join(dir, p)
-		mp, err := getPatternMinTime(p)
+	include, exclude := splitPatterns(dir, patterns)
+	for _, p := range include {
+		mp, err := getPatternMinTime(p, exclude)
 		if err != nil {
 			return time.Time{}, err
 		}
@@ -33,9 +34,9 @@ func getPatternsMinTime(dir string, patterns []string) (m time.Time, err error)
 	return
 }
 func getPatternsMaxTime(dir string, patterns []string) (m time.Time, err error) {
-	for _, p := range patterns {
-		p = filepath.Join(dir, p)
-		mp, err := getPatternMaxTime(p)
+	include, exclude := splitPatterns(dir, patterns)
+	for _, p := range include {
+		mp, err := getPatternMaxTime(p, exclude)
 		if err != nil {
 			return time.Time{}, err
 		}
@@ -44,8 +45,8 @@ func getPatternsMaxTime(dir string, patterns []string) (m time.Time, err error)
 	return
 }
 
-func getPatternMinTime(pattern string) (minTime time.Time, err error) {
-	files, err := zglob.Glob(pattern)
+func getPatternMinTime(pattern string, exclude []string) (minTime time.Time, err error) {
+	files, err := globPattern(pattern, exclude)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -64,8 +65,8 @@ func getPatternMinTime(pattern string) (minTime time.Time, err error) {
 	return
 }
 
-func getPatternMaxTime(pattern string) (maxTime time.Time, err error) {
-	files, err := zglob.Glob(pattern)
+func getPatternMaxTime(pattern string, exclude []string) (maxTime time.Time, err error) {
+	files, err := globPattern(pattern, exclude)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -83,3 +84,64 @@ func getPatternMaxTime(pattern string) (maxTime time.Time, err error) {
 	}
 	return
 }
+
+// splitPatterns separates the patterns of the files to include from the ones
+// of the files to exclude, which start with "!", and joins them to dir
+func splitPatterns(dir string, patterns []string) (include, exclude []string) {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			exclude = append(exclude, filepath.Join(dir, strings.TrimPrefix(p, "!")))
+			continue
+		}
+		include = append(include, filepath.Join(dir, p))
+	}
+	return
+}
+
+// globPattern returns the files matched by pattern, except the excluded ones
+func globPattern(pattern string, exclude []string) ([]string, error) {
+	files, err := zglob.Glob(pattern)
+	if err != nil || len(exclude) == 0 {
+		return files, err
+	}
+
+	included := files[:0]
+	for _, f := range files {
+		if !isExcluded(f, exclude) {
+			included = append(included, f)
+		}
+	}
+	return included, nil
+}
+
+// isExcluded tells if path, or a directory containing it, is matched by any
+// of the exclude patterns, so "!node_modules" excludes all the files in it
+func isExcluded(path string, exclude []string) bool {
+	for {
+		for _, e := range exclude {
+			if ok, _ := zglob.Match(e, path); ok {
+				return true
+			}
+		}
+		parent := filepath.Dir(path)
+		if parent == path || parent == "." {
+			return false
+		}
+		path = parent
+	}
+}
+
+// globRoot returns the directory where the files matched by pattern are
+func globRoot(pattern string) string {
+	var root []string
+	for _, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if strings.Contains(part, "*") {
+			if len(root) == 0 {
+				return "."
+			}
+			return filepath.FromSlash(strings.Join(root, "/"))
+		}
+		root = append(root, part)
+	}
+	return filepath.Dir(pattern)
+}
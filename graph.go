@@ -0,0 +1,177 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// GraphEdgeDep is the kind of the edges from a task to its deps
+	GraphEdgeDep = "dep"
+	// GraphEdgeCall is the kind of the edges from a task to the tasks it calls
+	GraphEdgeCall = "call"
+)
+
+// Graph is the dependency graph of the tasks
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a task in the graph
+type GraphNode struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// GraphEdge is either a dep or a call from a task to another
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph returns the graph of all tasks or, if roots are given, of them and
+// the tasks they reach
+func (e *Executor) Graph(roots ...string) (*Graph, error) {
+	if len(roots) == 0 {
+		for name := range e.Tasks {
+			roots = append(roots, name)
+		}
+	}
+
+	for _, r := range roots {
+		if _, ok := e.Tasks[r]; !ok {
+			return nil, &TaskNotFoundError{r}
+		}
+	}
+
+	var (
+		g       = &Graph{}
+		visited = make(map[string]struct{})
+		names   []string
+	)
+	var visit func(string)
+	visit = func(name string) {
+		if _, ok := visited[name]; ok {
+			return
+		}
+		visited[name] = struct{}{}
+		names = append(names, name)
+
+		// edges to undefined tasks are kept, so they show up in the graph
+		t, ok := e.Tasks[name]
+		if !ok {
+			return
+		}
+
+		var edges []*GraphEdge
+		for _, d := range t.Deps {
+			edges = append(edges, &GraphEdge{From: name, To: d.Task, Kind: GraphEdgeDep})
+		}
+		for _, c := range t.allCmds() {
+			if c.Task != "" {
+				edges = append(edges, &GraphEdge{From: name, To: c.Task, Kind: GraphEdgeCall})
+			}
+		}
+		g.Edges = append(g.Edges, edges...)
+		for _, edge := range edges {
+			visit(edge.To)
+		}
+	}
+	for _, r := range roots {
+		visit(r)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		n := &GraphNode{Name: name}
+		if t, ok := e.Tasks[name]; ok {
+			n.Desc = t.Desc
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		return g.Edges[i].From < g.Edges[j].From
+	})
+	return g, nil
+}
+
+// WriteGraph writes the graph of the given tasks (or of all tasks) to w in
+// format, which should be either "dot", "json" or "mermaid"
+func (e *Executor) WriteGraph(w io.Writer, format string, roots ...string) error {
+	g, err := e.Graph(roots...)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "dot":
+		return g.writeDOT(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	case "mermaid":
+		return g.writeMermaid(w)
+	default:
+		return &unknownGraphFormatError{format}
+	}
+}
+
+func (g *Graph) writeDOT(w io.Writer) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+	fmt.Fprintln(w, "digraph tasks {")
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Desc != "" {
+			label += "\n" + n.Desc
+		}
+		fmt.Fprintf(w, "\t\"%s\" [label=\"%s\"];\n", quote(n.Name), quote(label))
+	}
+	for _, edge := range g.Edges {
+		attrs := ""
+		if edge.Kind == GraphEdgeCall {
+			attrs = " [style=dashed]"
+		}
+		fmt.Fprintf(w, "\t\"%s\" -> \"%s\"%s;\n", quote(edge.From), quote(edge.To), attrs)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (g *Graph) writeMermaid(w io.Writer) error {
+	quote := strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace
+
+	ids := make(map[string]string, len(g.Nodes))
+	id := func(name string) string {
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprintf("t%d", len(ids))
+		}
+		return ids[name]
+	}
+
+	fmt.Fprintln(w, "graph TD")
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Desc != "" {
+			label += "\n" + n.Desc
+		}
+		fmt.Fprintf(w, "\t%s[\"%s\"]\n", id(n.Name), quote(label))
+	}
+	for _, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Kind == GraphEdgeCall {
+			arrow = "-.->"
+		}
+		_, err := fmt.Fprintf(w, "\t%s %s %s\n", id(edge.From), arrow, id(edge.To))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package task_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-task/task"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraph(t *testing.T) {
+	e := &task.Executor{
+		Tasks: task.Tasks{
+			"build": &task.Task{
+				Desc: "Builds",
+				Deps: []*task.Dep{{Task: "generate"}},
+				Cmds: []*task.Cmd{{Task: "lint"}, {Cmd: "go build"}},
+			},
+			"generate": &task.Task{},
+			"lint":     &task.Task{},
+			"other":    &task.Task{},
+		},
+	}
+
+	g, err := e.Graph("build")
+	assert.NoError(t, err)
+	assert.Equal(t, []*task.GraphNode{
+		{Name: "build", Desc: "Builds"},
+		{Name: "generate"},
+		{Name: "lint"},
+	}, g.Nodes)
+	assert.Equal(t, []*task.GraphEdge{
+		{From: "build", To: "generate", Kind: task.GraphEdgeDep},
+		{From: "build", To: "lint", Kind: task.GraphEdgeCall},
+	}, g.Edges)
+
+	g, err = e.Graph()
+	assert.NoError(t, err)
+	assert.Len(t, g.Nodes, 4)
+
+	buff := bytes.NewBuffer(nil)
+	assert.NoError(t, e.WriteGraph(buff, "dot", "build"))
+	assert.Equal(t, `digraph tasks {
+	"build" [label="build\nBuilds"];
+	"generate" [label="generate"];
+	"lint" [label="lint"];
+	"build" -> "generate";
+	"build" -> "lint" [style=dashed];
+}
+`, buff.String())
+
+	buff.Reset()
+	assert.NoError(t, e.WriteGraph(buff, "mermaid", "build"))
+	assert.Equal(t, `graph TD
+	t0["build<br/>Builds"]
+	t1["generate"]
+	t2["lint"]
+	t0 --> t1
+	t0 -.-> t2
+`, buff.String())
+
+	assert.Error(t, e.WriteGraph(buff, "svg"))
+	assert.Error(t, e.WriteGraph(buff, "json", "missing"))
+}
@@ -1,22 +1,114 @@
 package task
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 	"text/tabwriter"
 )
 
+// TaskInfo is the information about a task given by ListTasks
+type TaskInfo struct {
+	Name      string   `json:"name"`
+	Desc      string   `json:"desc"`
+	Deps      []string `json:"deps"`
+	Sources   []string `json:"sources"`
+	Generates []string `json:"generates"`
+	Dir       string   `json:"dir"`
+	UpToDate  bool     `json:"up_to_date"`
+}
+
+// ListTasks prints the tasks that have a description or, if all is true, all
+// tasks. If asJSON is true, they are printed as a JSON array of TaskInfo,
+// which also tells if each task is up to date.
+func (e *Executor) ListTasks(all, asJSON bool) error {
+	e.setDefaultStdio()
+
+	tasks := e.tasksWithDesc()
+	if all {
+		tasks = e.allTasks()
+	}
+
+	if !asJSON {
+		e.printTasks(tasks)
+		return nil
+	}
+
+	infos := make([]*TaskInfo, len(tasks))
+	for i, name := range tasks {
+		info, err := e.getTaskInfo(name)
+		if err != nil {
+			return err
+		}
+		infos[i] = info
+	}
+
+	enc := json.NewEncoder(e.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(infos)
+}
+
+func (e *Executor) getTaskInfo(name string) (*TaskInfo, error) {
+	var (
+		t    = e.Tasks[name]
+		call = Call{Task: name}
+	)
+
+	dir, err := e.getTaskDir(call)
+	if err != nil {
+		return nil, err
+	}
+	upToDate, err := e.isTaskUpToDate(context.Background(), call)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([]string, len(t.Deps))
+	for i, d := range t.Deps {
+		deps[i] = d.Task
+	}
+	return &TaskInfo{
+		Name:      name,
+		Desc:      t.Desc,
+		Deps:      deps,
+		Sources:   nonNil(t.Sources),
+		Generates: nonNil(t.Generates),
+		Dir:       dir,
+		UpToDate:  upToDate,
+	}, nil
+}
+
+// nonNil makes nil slices be encoded as empty JSON arrays
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
 func (e *Executor) printExistingTasksHelp() {
 	tasks := e.tasksWithDesc()
 	if len(tasks) == 0 {
 		return
 	}
 	e.println("Available tasks for this project:")
+	e.printTasks(tasks)
+}
 
-	// Format in tab-separated columns with a tab stop of 8.
-	w := tabwriter.NewWriter(e.Stdout, 0, 8, 0, '\t', 0)
+func (e *Executor) printTasks(tasks []string) {
+	// Format in tab-separated columns with a tab stop of 8, with at least one
+	// tab after the names, so the descriptions never stick to them.
+	w := tabwriter.NewWriter(e.Stdout, 0, 8, 1, '\t', 0)
 	for _, task := range tasks {
-		fmt.Fprintln(w, fmt.Sprintf("- %s:\t%s", task, e.Tasks[task].Desc))
+		if desc := e.Tasks[task].Desc; desc != "" {
+			// one line per task, which the completion scripts rely on
+			desc = strings.Replace(strings.TrimSpace(desc), "\n", " ", -1)
+			fmt.Fprintln(w, fmt.Sprintf("- %s:\t%s", task, desc))
+		} else {
+			fmt.Fprintln(w, fmt.Sprintf("- %s", task))
+		}
 	}
 	w.Flush()
 }
@@ -30,3 +122,11 @@ func (e *Executor) tasksWithDesc() (tasks []string) {
 	sort.Strings(tasks)
 	return
 }
+
+func (e *Executor) allTasks() (tasks []string) {
+	for name := range e.Tasks {
+		tasks = append(tasks, name)
+	}
+	sort.Strings(tasks)
+	return
+}
@@ -9,9 +9,12 @@ import (
 
 const defaultTaskfile = `# github.com/go-task/task
 
-default:
-  cmds:
-    - echo "Hello, World!"
+version: '2'
+
+tasks:
+  default:
+    cmds:
+      - echo "Hello, World!"
 `
 
 // InitTaskfile Taskfile creates a new Taskfile
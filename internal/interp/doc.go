@@ -6,4 +6,9 @@
 //
 // This package is a work in progress and EXPERIMENTAL; its API is not
 // subject to the 1.x backwards compatibility guarantee.
+//
+// It's a copy of github.com/mvdan/sh/interp at revision 7545ea3, the one in
+// Gopkg.lock, with the Exec field added to Runner, so Task can start the
+// commands in their own process group. It can be dropped once upstream has a
+// way to run the commands.
 package interp
@@ -74,6 +74,11 @@ type Runner struct {
 	// Context can be used to cancel the interpreter before it finishes
 	Context context.Context
 
+	// Exec, if not nil, is used to run the external commands instead of
+	// cmd.Run. It's then responsible for stopping them when Context is
+	// done.
+	Exec func(cmd *exec.Cmd) error
+
 	stopOnCmdErr bool // set -e
 }
 
@@ -767,7 +772,12 @@ func (r *Runner) call(pos syntax.Pos, name string, args []string) {
 		r.exit = r.builtinCode(pos, name, args)
 		return
 	}
-	cmd := exec.CommandContext(r.Context, name, args...)
+	var cmd *exec.Cmd
+	if r.Exec != nil {
+		cmd = exec.Command(name, args...)
+	} else {
+		cmd = exec.CommandContext(r.Context, name, args...)
+	}
 	cmd.Env = r.Env
 	for name, val := range r.cmdVars {
 		cmd.Env = append(cmd.Env, name+"="+varStr(val))
@@ -776,7 +786,12 @@ func (r *Runner) call(pos syntax.Pos, name string, args []string) {
 	cmd.Stdin = r.Stdin
 	cmd.Stdout = r.Stdout
 	cmd.Stderr = r.Stderr
-	err := cmd.Run()
+	var err error
+	if r.Exec != nil {
+		err = r.Exec(cmd)
+	} else {
+		err = cmd.Run()
+	}
 	switch x := err.(type) {
 	case *exec.ExitError:
 		// started, but errored - default to 1 if OS
@@ -0,0 +1,128 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Output modes, which set how the output of tasks is written
+const (
+	// OutputInterleaved writes the output of tasks as soon as it's written by
+	// the commands, so the output of concurrent tasks is mixed. It's the
+	// default.
+	OutputInterleaved = "interleaved"
+	// OutputPrefixed writes each line of the output prefixed by the name of the
+	// task, like "[build] ok"
+	OutputPrefixed = "prefixed"
+	// OutputGroup buffers the output of each task, and writes it all at once
+	// when the task finishes
+	OutputGroup = "group"
+)
+
+func (e *Executor) checkOutput() error {
+	switch e.Output {
+	case "", OutputInterleaved, OutputPrefixed, OutputGroup:
+		return nil
+	default:
+		return &invalidOutputError{e.Output}
+	}
+}
+
+// taskOutput returns the writers for the stdout and the stderr of a task,
+// according to the output mode, and a function that must be called when the
+// task finishes. The writers of the caller of the task, if any, are used for
+// the group mode, so the output of a called task is written in order within
+// the one of its caller.
+func (e *Executor) taskOutput(task string, callerStdout, callerStderr io.Writer) (stdout, stderr io.Writer, finish func() error) {
+	switch e.Output {
+	case OutputPrefixed:
+		prefix := fmt.Sprintf("[%s] ", task)
+		outWriter := &prefixWriter{mutex: &e.outputMutex, w: e.Stdout, prefix: prefix}
+		errWriter := &prefixWriter{mutex: &e.outputMutex, w: e.Stderr, prefix: prefix}
+		return outWriter, errWriter, func() error {
+			if err := outWriter.Close(); err != nil {
+				return err
+			}
+			return errWriter.Close()
+		}
+	case OutputGroup:
+		if callerStdout != nil {
+			return callerStdout, callerStderr, func() error { return nil }
+		}
+		var outBuff, errBuff bytes.Buffer
+		return &syncWriter{w: &outBuff}, &syncWriter{w: &errBuff}, func() error {
+			e.outputMutex.Lock()
+			defer e.outputMutex.Unlock()
+
+			if _, err := outBuff.WriteTo(e.Stdout); err != nil {
+				return err
+			}
+			_, err := errBuff.WriteTo(e.Stderr)
+			return err
+		}
+	default:
+		return e.Stdout, e.Stderr, func() error { return nil }
+	}
+}
+
+// syncWriter is a writer safe for concurrent use, as commands can write from
+// many goroutines
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.w.Write(p)
+}
+
+// prefixWriter writes each line prefixed. Only whole lines are written, while
+// holding mutex, so lines of different tasks are not mixed.
+type prefixWriter struct {
+	mutex  *sync.Mutex
+	w      io.Writer
+	prefix string
+
+	buff bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buff.Write(p)
+	for {
+		i := bytes.IndexByte(w.buff.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buff.Next(i + 1)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Close writes the last line, if it doesn't end with a new line
+func (w *prefixWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.buff.Len() == 0 {
+		return nil
+	}
+	line := append(w.buff.Bytes(), '\n')
+	w.buff.Reset()
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	if _, err := io.WriteString(w.w, w.prefix); err != nil {
+		return err
+	}
+	_, err := w.w.Write(line)
+	return err
+}
@@ -4,48 +4,259 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/imdario/mergo"
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// taskfileVersion is the version of the current Taskfile format. Taskfiles
+	// without a version are in the legacy format
+	taskfileVersion = "2"
+	// namespaceSeparator separates the namespace of included tasks from their
+	// names, like in "api:build"
+	namespaceSeparator = ":"
+)
+
 // ReadTaskfile parses Taskfile from the disk
 func (e *Executor) ReadTaskfile() error {
 	path := filepath.Join(e.Dir, TaskFilePath)
 
-	var err error
-	e.Tasks, err = e.readTaskfileData(path)
+	e.taskfiles = nil
+	tf, err := e.readTaskfile(path, "", "", nil)
 	if err != nil {
 		return err
 	}
 
-	osTasks, err := e.readTaskfileData(fmt.Sprintf("%s_%s", path, runtime.GOOS))
+	e.Tasks = tf.Tasks
+	if e.Output == "" {
+		e.Output = tf.Output
+	}
+	return nil
+}
+
+// readTaskfile reads the Taskfile in path (without extension) merged with its
+// OS specific Taskfile and the tasks of the Taskfiles it includes. The names of its tasks
+// and the tasks they refer to are prefixed with namespace, and dir, the
+// directory of the Taskfile relative to Executor.Dir, is the default dir of
+// its tasks. parents are the Taskfiles including this one, to detect cycles.
+func (e *Executor) readTaskfile(path, namespace, dir string, parents []string) (*Taskfile, error) {
+	for _, p := range parents {
+		if p == path {
+			return nil, &cyclicIncludeError{path}
+		}
+	}
+	parents = append(parents, path)
+
+	tf, err := e.readTaskfileData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	osTaskfile, err := e.readTaskfileData(fmt.Sprintf("%s_%s", path, runtime.GOOS))
 	if err != nil {
-		switch err.(type) {
-		case taskFileNotFound:
-			return nil
-		default:
-			return err
+		if _, ok := err.(*TaskfileNotFoundError); !ok {
+			return nil, err
 		}
+	} else {
+		if err := mergo.MapWithOverwrite(&tf.Tasks, osTaskfile.Tasks); err != nil {
+			return nil, err
+		}
+		tf.Vars = mergeVars(tf.Vars, osTaskfile.Vars)
+		tf.Env = mergeVars(tf.Env, osTaskfile.Env)
 	}
-	if err := mergo.MapWithOverwrite(&e.Tasks, osTasks); err != nil {
-		return err
+
+	result := make(Tasks, len(tf.Tasks))
+	for name, t := range tf.Tasks {
+		if t == nil {
+			t = &Task{}
+		}
+		t.Vars = mergeVars(tf.Vars, t.Vars)
+		t.Env = mergeVars(tf.Env, t.Env)
+		namespaceTask(t, namespace, dir)
+		result[namespacedName(namespace, name)] = t
 	}
-	return nil
+
+	for ns, includePath := range tf.Includes {
+		includeDir, includePath := resolveInclude(filepath.Dir(path), includePath)
+		relDir, err := filepath.Rel(e.Dir, includeDir)
+		if err != nil {
+			relDir = includeDir
+		}
+
+		included, err := e.readTaskfile(includePath, namespacedName(namespace, ns), relDir, parents)
+		if err != nil {
+			return nil, err
+		}
+		for name, t := range included.Tasks {
+			if _, ok := result[name]; ok {
+				return nil, &duplicatedTaskError{name}
+			}
+			result[name] = t
+		}
+	}
+
+	tf.Tasks = result
+	return tf, nil
+}
+
+// resolveInclude returns the directory and the path without extension of an
+// included Taskfile. The included path is relative to dir, and can either be
+// a Taskfile or a directory containing one.
+func resolveInclude(dir, include string) (includeDir, path string) {
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(dir, include)
+	}
+	if info, err := os.Stat(include); err == nil && info.IsDir() {
+		return include, filepath.Join(include, TaskFilePath)
+	}
+	return filepath.Dir(include), strings.TrimSuffix(include, filepath.Ext(include))
+}
+
+// namespaceTask prefixes the tasks t refers to with namespace and makes its
+// dir relative to dir. Names starting with the namespace separator, like
+// ":build", refer to the tasks of the root Taskfile.
+func namespaceTask(t *Task, namespace, dir string) {
+	for _, d := range t.Deps {
+		d.Task = namespacedName(namespace, d.Task)
+	}
+	for _, c := range t.allCmds() {
+		if c.Task != "" {
+			c.Task = namespacedName(namespace, c.Task)
+		}
+	}
+	if dir != "" && !filepath.IsAbs(t.Dir) {
+		t.Dir = filepath.Join(dir, t.Dir)
+	}
+}
+
+// mergeVars returns the variables in base overridden by the ones in override,
+// like the variables of a Taskfile by the ones of its tasks
+func mergeVars(base, override Vars) Vars {
+	if len(base) == 0 {
+		return override
+	}
+	vars := make(Vars, len(base)+len(override))
+	for k, v := range base {
+		vars[k] = v
+	}
+	for k, v := range override {
+		vars[k] = v
+	}
+	return vars
+}
+
+func namespacedName(namespace, name string) string {
+	if strings.HasPrefix(name, namespaceSeparator) {
+		return strings.TrimPrefix(name, namespaceSeparator)
+	}
+	if namespace == "" {
+		return name
+	}
+	return namespace + namespaceSeparator + name
+}
+
+// Taskfile represents a Taskfile, in either of its formats: the versioned
+// one, with file-wide settings next to the tasks, or the legacy one, which is
+// just a map of tasks
+type Taskfile struct {
+	Version  string
+	Includes map[string]string
+	Vars     Vars
+	Env      Vars
+	Output   string
+	Tasks    Tasks
+}
+
+func (e *Executor) readTaskfileData(path string) (*Taskfile, error) {
+	file, unmarshal, err := openTaskfile(path)
+	if err != nil {
+		return nil, err
+	}
+	e.taskfiles = append(e.taskfiles, file)
+
+	version, err := getTaskfileVersion(unmarshal)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case "":
+		return readLegacyTaskfile(unmarshal)
+	case taskfileVersion:
+		var data struct {
+			Includes map[string]string
+			Vars     Vars
+			Env      Vars
+			Output   string
+			Tasks    Tasks
+		}
+		if err := unmarshal(&data); err != nil {
+			return nil, err
+		}
+		return &Taskfile{
+			Version:  version,
+			Includes: data.Includes,
+			Vars:     data.Vars,
+			Env:      data.Env,
+			Output:   data.Output,
+			Tasks:    data.Tasks,
+		}, nil
+	default:
+		return nil, &unsupportedVersionError{path, version}
+	}
+}
+
+// taskfileExtensions are the supported Taskfile formats, in order of
+// precedence, and their unmarshal functions
+var (
+	taskfileExtensions  = []string{".yml", ".json", ".toml"}
+	taskfileUnmarshaler = map[string]func([]byte, interface{}) error{
+		".yml":  yaml.Unmarshal,
+		".json": json.Unmarshal,
+		".toml": toml.Unmarshal,
+	}
+)
+
+// openTaskfile finds the Taskfile in path (without extension) in any of the
+// supported formats, returning its file name and a function to unmarshal it
+func openTaskfile(path string) (file string, unmarshal func(interface{}) error, err error) {
+	for _, ext := range taskfileExtensions {
+		b, err := ioutil.ReadFile(path + ext)
+		if err != nil {
+			continue
+		}
+		u := taskfileUnmarshaler[ext]
+		return path + ext, func(v interface{}) error { return u(b, v) }, nil
+	}
+	return "", nil, &TaskfileNotFoundError{path}
 }
 
-func (e *Executor) readTaskfileData(path string) (tasks map[string]*Task, err error) {
-	if b, err := ioutil.ReadFile(path + ".yml"); err == nil {
-		return tasks, yaml.Unmarshal(b, &tasks)
+// getTaskfileVersion returns the version of a Taskfile, or an empty string for
+// the legacy format, in which "version" may be the name of a task
+func getTaskfileVersion(unmarshal func(interface{}) error) (string, error) {
+	var data map[string]interface{}
+	if err := unmarshal(&data); err != nil {
+		return "", err
 	}
-	if b, err := ioutil.ReadFile(path + ".json"); err == nil {
-		return tasks, json.Unmarshal(b, &tasks)
+	switch v := data["version"].(type) {
+	case string:
+		return v, nil
+	case int, int64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", nil
 	}
-	if b, err := ioutil.ReadFile(path + ".toml"); err == nil {
-		return tasks, toml.Unmarshal(b, &tasks)
+}
+
+func readLegacyTaskfile(unmarshal func(interface{}) error) (*Taskfile, error) {
+	var tf Taskfile
+	if err := unmarshal(&tf.Tasks); err != nil {
+		return nil, err
 	}
-	return nil, taskFileNotFound{path}
+	return &tf, nil
 }
@@ -0,0 +1,143 @@
+package task
+
+import (
+	"encoding/json"
+)
+
+// SchemaFilePath is the file where the JSON Schema is shipped, kept in sync
+// with Schema by the tests
+const SchemaFilePath = "taskfile.schema.json"
+
+// Schema returns the JSON Schema of the Taskfile, for validation and
+// completion in editors. It describes both the versioned and the legacy
+// formats.
+func Schema() ([]byte, error) {
+	type object = map[string]interface{}
+
+	var (
+		str     = object{"type": "string"}
+		strList = object{"type": "array", "items": str}
+		vars    = object{
+			"type":                 "object",
+			"additionalProperties": object{"type": []string{"string", "number", "boolean"}},
+		}
+		ref = func(def string) object {
+			return object{"$ref": "#/definitions/" + def}
+		}
+		describe = func(o object, desc string) object {
+			described := object{"description": desc}
+			for k, v := range o {
+				described[k] = v
+			}
+			return described
+		}
+	)
+
+	cmds := object{
+		"type": "array",
+		"items": object{"oneOf": []object{
+			describe(str, `A shell command, or a call to another task if prefixed with "^"`),
+			ref("cmd"),
+		}},
+	}
+
+	taskProperties := object{
+		"cmds":    describe(cmds, "Commands to be run, in order"),
+		"finally": describe(cmds, "Commands always run after the commands of the task, even if they fail"),
+		"deps": describe(object{
+			"type": "array",
+			"items": object{"oneOf": []object{
+				describe(str, "The name of the task"),
+				ref("dep"),
+			}},
+		}, "Tasks to be run, concurrently, before this one"),
+		"desc":      describe(str, "Description of the task, shown on the help"),
+		"sources":   describe(strList, `File patterns of the sources of the task, used to check if it's up to date. Patterns starting with "!" exclude files`),
+		"generates": describe(strList, "File patterns of the files generated by the task, used to check if it's up to date"),
+		"status":    describe(strList, "Commands that should all succeed if the task is up to date"),
+		"dir":       describe(str, "Directory where the commands are run, relative to the Taskfile"),
+		"vars":      describe(vars, "Variables of the task"),
+		"set":       describe(str, "Name of a variable to store the output of the commands"),
+		"env":       describe(vars, "Environment variables of the commands"),
+		"method": describe(object{
+			"type": "string",
+			"enum": []string{methodTimestamp, methodChecksum},
+		}, "How sources are checked to decide if the task is up to date"),
+		"ignore_error": describe(object{"type": "boolean"}, "Keeps running the task when a command fails, logging the error"),
+	}
+
+	schema := object{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Taskfile",
+		"description": "Task definitions for github.com/go-task/task",
+		"oneOf": []object{
+			ref("taskfile"),
+			describe(object{
+				"type":                 "object",
+				"additionalProperties": ref("task"),
+			}, "Legacy format, a map of task names to tasks"),
+		},
+		"definitions": object{
+			"taskfile": object{
+				"type":     "object",
+				"required": []string{"version"},
+				"properties": object{
+					"version": describe(object{
+						"type": []string{"string", "number"},
+						"enum": []interface{}{taskfileVersion, 2},
+					}, "Version of the Taskfile format"),
+					"includes": ref("includes"),
+					"vars":     describe(vars, "Variables available to all tasks"),
+					"env":      describe(vars, "Environment variables of all tasks"),
+					"output": describe(object{
+						"type": "string",
+						"enum": []string{OutputInterleaved, OutputPrefixed, OutputGroup},
+					}, "How the output of tasks is written"),
+					"tasks": describe(object{
+						"type":                 "object",
+						"additionalProperties": ref("task"),
+					}, "The tasks, by name"),
+				},
+				"additionalProperties": false,
+			},
+			"includes": describe(object{
+				"type":                 "object",
+				"additionalProperties": str,
+			}, "Taskfiles (or directories containing one) to include, by namespace"),
+			"task": object{
+				"type":                 "object",
+				"properties":           taskProperties,
+				"additionalProperties": false,
+			},
+			"cmd": object{
+				"type": "object",
+				"properties": object{
+					"cmd":          describe(str, "A shell command"),
+					"task":         describe(str, "Name of the task to call"),
+					"vars":         describe(vars, "Variables given to the called task"),
+					"ignore_error": describe(object{"type": "boolean"}, "Logs the error instead of failing the task if the command fails"),
+				},
+				"oneOf": []object{
+					{"required": []string{"cmd"}},
+					{"required": []string{"task"}},
+				},
+				"additionalProperties": false,
+			},
+			"dep": object{
+				"type": "object",
+				"properties": object{
+					"task": describe(str, "Name of the task"),
+					"vars": describe(vars, "Variables given to the task"),
+				},
+				"required":             []string{"task"},
+				"additionalProperties": false,
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,55 @@
+package task_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-task/task"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	b, err := task.Schema()
+	assert.NoError(t, err)
+
+	shipped, err := ioutil.ReadFile(task.SchemaFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(b), string(shipped), `%s is outdated, run "task schema"`, task.SchemaFilePath)
+
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(b, &schema))
+
+	for def, v := range map[string]interface{}{
+		"taskfile": task.Taskfile{},
+		"task":     task.Task{},
+		"cmd":      task.Cmd{},
+		"dep":      task.Dep{},
+	} {
+		typ := reflect.TypeOf(v)
+		var keys []string
+		for i := 0; i < typ.NumField(); i++ {
+			key := strings.ToLower(typ.Field(i).Name)
+			if tag := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]; tag != "" {
+				key = tag
+			}
+			keys = append(keys, key)
+		}
+
+		var properties []string
+		for p := range schema.Definitions[def].Properties {
+			properties = append(properties, p)
+		}
+		sort.Strings(keys)
+		sort.Strings(properties)
+		assert.Equal(t, keys, properties, "Schema of %s is out of sync with %s", def, typ)
+	}
+}
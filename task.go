@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-task/task/execext"
 
@@ -25,12 +27,62 @@ type Executor struct {
 	Dir   string
 	Force bool
 	Watch bool
+	Dry   bool
+
+	// KeepGoing makes the tasks that don't depend on a failed task run anyway,
+	// like "make -k". Run then returns an error listing all the failures.
+	KeepGoing bool
+
+	// Concurrency is the maximum number of commands run at the same time in
+	// the whole graph of tasks, counting the status commands and the dynamic
+	// variables too. Zero means no limit.
+	Concurrency int
+
+	// GracePeriod is how long the running commands have to exit after the
+	// context given to RunContext is canceled, before they are killed. They
+	// are sent SIGTERM along with the processes they started, which are in a
+	// process group of their own unless they read the terminal task runs in
+	// the foreground of. No other command is started in the meantime, but the
+	// finally commands, which have GracePeriod to finish too.
+	GracePeriod time.Duration
+
+	// Output is how the output of tasks is written: OutputInterleaved (the
+	// default), OutputPrefixed or OutputGroup
+	Output string
+
+	// Vars are variables given on the command line, which take precedence
+	// over all others
+	Vars Vars
 
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// taskfiles are the files read by ReadTaskfile
+	taskfiles []string
+
 	watchingFiles map[string]struct{}
+	watchingDirs  map[string]struct{}
+
+	taskRunsMutex sync.Mutex
+	taskRuns      map[string]*taskRun
+	failures      []error
+
+	outputMutex sync.Mutex
+
+	concurrencyOnce      sync.Once
+	concurrencySemaphore chan struct{}
+
+	killOnce sync.Once
+	killCtx  context.Context
+	kill     context.CancelFunc
+}
+
+// taskRun holds the state of a task run started by runTaskOnce
+type taskRun struct {
+	done chan struct{}
+	set  Vars
+	err  error
 }
 
 // Tasks representas a group of tasks
@@ -38,142 +90,353 @@ type Tasks map[string]*Task
 
 // Task represents a task
 type Task struct {
-	Cmds      []string
-	Deps      []string
-	Desc      string
-	Sources   []string
-	Generates []string
-	Status    []string
-	Dir       string
-	Vars      map[string]string
-	Set       string
-	Env       map[string]string
+	Cmds        []*Cmd
+	Deps        []*Dep
+	Desc        string
+	Sources     []string
+	Generates   []string
+	Status      []string
+	Dir         string
+	Vars        Vars
+	Set         string
+	Env         Vars
+	Method      string
+	IgnoreError bool `yaml:"ignore_error" json:"ignore_error" toml:"ignore_error"`
+	Finally     []*Cmd
+}
+
+// allCmds returns the commands of the task followed by its finally commands
+func (t *Task) allCmds() []*Cmd {
+	cmds := make([]*Cmd, 0, len(t.Cmds)+len(t.Finally))
+	cmds = append(cmds, t.Cmds...)
+	return append(cmds, t.Finally...)
 }
 
 // Run runs Task
 func (e *Executor) Run(args ...string) error {
-	if e.HasCyclicDep() {
-		return ErrCyclicDependencyDetected
-	}
+	return e.RunContext(context.Background(), args...)
+}
 
-	if e.Stdin == nil {
-		e.Stdin = os.Stdin
-	}
-	if e.Stdout == nil {
-		e.Stdout = os.Stdout
+// RunContext runs the given tasks like Run. When ctx is canceled, no other
+// task or command is started, the running commands are sent SIGTERM and they
+// are killed after GracePeriod, or by Kill.
+func (e *Executor) RunContext(ctx context.Context, args ...string) error {
+	if err := e.CheckCyclicDep(); err != nil {
+		return err
 	}
-	if e.Stderr == nil {
-		e.Stderr = os.Stderr
+	if err := e.checkOutput(); err != nil {
+		return err
 	}
 
+	e.setDefaultStdio()
+
 	// check if given tasks exist
 	for _, a := range args {
 		if _, ok := e.Tasks[a]; !ok {
 			// FIXME: move to the main package
 			e.printExistingTasksHelp()
-			return &taskNotFoundError{taskName: a}
+			return &TaskNotFoundError{taskName: a}
 		}
 	}
 
+	ctx, cancel := withGracePeriod(ctx, e.killContext(), e.GracePeriod)
+	defer cancel()
+
 	if e.Watch {
-		if err := e.watchTasks(args...); err != nil {
+		if err := e.watchTasks(ctx, args...); err != nil {
 			return err
 		}
 		return nil
 	}
 
+	e.resetRunState()
 	for _, a := range args {
-		if err := e.RunTask(context.Background(), a); err != nil {
+		if _, err := e.runTaskOnce(ctx, Call{Task: a}); err != nil && !e.KeepGoing {
 			return err
 		}
 	}
+
+	e.taskRunsMutex.Lock()
+	defer e.taskRunsMutex.Unlock()
+	if len(e.failures) > 0 {
+		return &tasksFailedError{e.failures}
+	}
 	return nil
 }
 
-// RunTask runs a task by its name
-func (e *Executor) RunTask(ctx context.Context, name string) error {
-	t, ok := e.Tasks[name]
+// Kill kills the running commands right away, finally commands included,
+// instead of waiting for GracePeriod. No command is run by the Executor
+// anymore.
+func (e *Executor) Kill() {
+	e.killContext()
+	e.kill()
+}
+
+// killContext returns the context canceled by Kill
+func (e *Executor) killContext() context.Context {
+	e.killOnce.Do(func() {
+		e.killCtx, e.kill = context.WithCancel(context.Background())
+	})
+	return e.killCtx
+}
+
+// setDefaultStdio makes the unset standard input and outputs be the ones of
+// the process
+func (e *Executor) setDefaultStdio() {
+	if e.Stdin == nil {
+		e.Stdin = os.Stdin
+	}
+	if e.Stdout == nil {
+		e.Stdout = os.Stdout
+	}
+	if e.Stderr == nil {
+		e.Stderr = os.Stderr
+	}
+}
+
+// RunTask runs a task by its name and the variables given by the caller
+func (e *Executor) RunTask(ctx context.Context, call Call) error {
+	_, err := e.runTask(ctx, call, nil, nil)
+	return err
+}
+
+// runTask runs a task like RunTask. A task called from a command of another
+// one is given the writers of the caller, so its output is part of the
+// caller's when it's grouped. It returns the variables set with "set" by the
+// task, its dependencies and the tasks it called, which are visible to what
+// runs after it.
+func (e *Executor) runTask(ctx context.Context, call Call, callerStdout, callerStderr io.Writer) (set Vars, err error) {
+	t, ok := e.Tasks[call.Task]
 	if !ok {
-		return &taskNotFoundError{name}
+		return nil, &TaskNotFoundError{call.Task}
 	}
 
-	if err := e.runDeps(ctx, name); err != nil {
-		return err
+	set, err = e.runDeps(ctx, call)
+	if err != nil {
+		return nil, err
 	}
+	call = call.withSetVars(set)
 
 	if !e.Force {
-		upToDate, err := e.isTaskUpToDate(ctx, name)
+		upToDate, err := e.isTaskUpToDate(ctx, call)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if upToDate {
-			e.printfln(`task: Task "%s" is up to date`, name)
-			return nil
+			e.printfln(`task: Task "%s" is up to date`, call.Task)
+			return set, nil
+		}
+	}
+
+	stdout, stderr, finishOutput := e.taskOutput(call.Task, callerStdout, callerStderr)
+	defer func() {
+		if finishErr := finishOutput(); err == nil {
+			err = finishErr
+		}
+	}()
+
+	defer func() {
+		// call is the one of the last command, seeing what the commands set
+		finallySet, finallyErr := e.runFinally(ctx, call, stdout, stderr)
+		set = mergeVars(set, finallySet)
+		if err == nil {
+			err = finallyErr
 		}
+	}()
+
+	for i, cmd := range t.Cmds {
+		cmdSet, err := e.runCommand(ctx, call, cmd, stdout, stderr)
+		if err != nil {
+			if cmd.IgnoreError || t.IgnoreError {
+				fmt.Fprintf(stderr, "task: Ignored error of command %d of task \"%s\": %v\n", i+1, call.Task, err)
+				continue
+			}
+			return nil, &TaskRunError{call.Task, i, err}
+		}
+		set = mergeVars(set, cmdSet)
+		call = call.withSetVars(cmdSet)
 	}
 
-	for i := range t.Cmds {
-		if err := e.runCommand(ctx, name, i); err != nil {
-			return &taskRunError{name, err}
+	if t.Method == methodChecksum && !e.Dry {
+		if err := e.writeChecksum(call); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return set, nil
 }
 
-func (e *Executor) runDeps(ctx context.Context, task string) error {
-	g, ctx := errgroup.WithContext(ctx)
-	t := e.Tasks[task]
+// runDeps runs the dependencies of the task of call, returning the variables
+// they set with "set". Each of them only sees the ones set before the task
+// was called, and not the ones set by the other dependencies, which run
+// concurrently.
+func (e *Executor) runDeps(ctx context.Context, call Call) (Vars, error) {
+	var g *errgroup.Group
+	if e.KeepGoing {
+		// a zero group doesn't cancel the other deps when one fails
+		g = &errgroup.Group{}
+	} else {
+		g, ctx = errgroup.WithContext(ctx)
+	}
+	t := e.Tasks[call.Task]
+	failed := false
+	sets := make([]Vars, len(t.Deps))
 
-	for _, d := range t.Deps {
-		dep := d
+	for i, d := range t.Deps {
+		i, dep := i, d
 
-		g.Go(func() error {
-			dep, err := e.ReplaceVariables(task, dep)
+		run := func() error {
+			depCall, err := e.getCall(call, dep.Task, dep.Vars)
 			if err != nil {
+				// runTaskOnce records the failures of tasks, but none was run
+				e.addFailure(err)
 				return err
 			}
 
-			if err = e.RunTask(ctx, dep); err != nil {
-				return err
+			sets[i], err = e.runTaskOnce(ctx, depCall)
+			return err
+		}
+
+		// on dry mode, deps are run serially so the plan is printed in order
+		if e.Dry {
+			if err := run(); err != nil {
+				if !e.KeepGoing {
+					return nil, err
+				}
+				failed = true
 			}
-			return nil
-		})
+			continue
+		}
+		g.Go(run)
 	}
 
 	if err := g.Wait(); err != nil {
-		return err
+		if !e.KeepGoing {
+			return nil, err
+		}
+		failed = true
 	}
-	return nil
+	if failed {
+		return nil, &depFailedError{call.Task}
+	}
+	var set Vars
+	for _, depSet := range sets {
+		set = mergeVars(set, depSet)
+	}
+	return set, nil
+}
+
+// getCall returns the call of a dep or command of the task being run by
+// caller, with the task name and variables values replaced
+func (e *Executor) getCall(caller Call, task string, vars Vars) (Call, error) {
+	task, err := e.ReplaceVariables(caller, task)
+	if err != nil {
+		return Call{}, err
+	}
+	vars, err = e.replaceCallVariables(caller, vars)
+	if err != nil {
+		return Call{}, err
+	}
+	return Call{Task: task, Vars: vars, setVars: caller.setVars}, nil
+}
+
+// runTaskOnce runs a task unless it was already run (or is running) with the
+// same variables since the last call to resetRunState, in which case it waits
+// for that run to finish and returns its result. This makes a task shared by
+// many others run only once.
+func (e *Executor) runTaskOnce(ctx context.Context, call Call) (Vars, error) {
+	key := call.key()
+
+	e.taskRunsMutex.Lock()
+	if e.taskRuns == nil {
+		e.taskRuns = make(map[string]*taskRun)
+	}
+	run, ok := e.taskRuns[key]
+	if !ok {
+		run = &taskRun{done: make(chan struct{})}
+		e.taskRuns[key] = run
+	}
+	e.taskRunsMutex.Unlock()
+
+	if ok {
+		select {
+		case <-run.done:
+			return run.set, run.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	run.set, run.err = e.runTask(ctx, call, nil, nil)
+	close(run.done)
+
+	// the failure of a dep was already recorded
+	if _, ok := run.err.(*depFailedError); !ok && run.err != nil {
+		e.addFailure(run.err)
+	}
+	return run.set, run.err
 }
 
-func (e *Executor) isTaskUpToDate(ctx context.Context, task string) (bool, error) {
-	t := e.Tasks[task]
+// addFailure records an error to be returned by RunContext in keep going mode
+func (e *Executor) addFailure(err error) {
+	if !e.KeepGoing {
+		return
+	}
+	e.taskRunsMutex.Lock()
+	e.failures = append(e.failures, err)
+	e.taskRunsMutex.Unlock()
+}
+
+// resetRunState forgets the tasks run and their failures, so a new invocation
+// starts from scratch
+func (e *Executor) resetRunState() {
+	e.taskRunsMutex.Lock()
+	e.taskRuns = nil
+	e.failures = nil
+	e.taskRunsMutex.Unlock()
+}
+
+func (e *Executor) isTaskUpToDate(ctx context.Context, call Call) (bool, error) {
+	t := e.Tasks[call.Task]
 
 	if len(t.Status) > 0 {
-		return e.isUpToDateStatus(ctx, task)
+		return e.isUpToDateStatus(ctx, call)
+	}
+
+	switch t.Method {
+	case "", methodTimestamp:
+		return e.isUpToDateTimestamp(ctx, call)
+	case methodChecksum:
+		return e.isUpToDateChecksum(ctx, call)
+	default:
+		return false, &invalidMethodError{call.Task, t.Method}
 	}
-	return e.isUpToDateTimestamp(ctx, task)
 }
 
-func (e *Executor) isUpToDateStatus(ctx context.Context, task string) (bool, error) {
-	t := e.Tasks[task]
+func (e *Executor) isUpToDateStatus(ctx context.Context, call Call) (bool, error) {
+	t := e.Tasks[call.Task]
 
-	environ, err := e.getEnviron(task)
+	environ, err := e.getEnviron(call)
 	if err != nil {
 		return false, err
 	}
-	dir, err := e.getTaskDir(task)
+	dir, err := e.getTaskDir(call)
 	if err != nil {
 		return false, err
 	}
 
 	for _, s := range t.Status {
+		release, err := e.acquireConcurrency(ctx)
+		if err != nil {
+			return false, err
+		}
 		err = execext.RunCommand(&execext.RunCommandOptions{
 			Context: ctx,
+			Stop:    stopContext(ctx),
 			Command: s,
 			Dir:     dir,
 			Env:     environ,
 		})
+		release()
 		if err != nil {
 			return false, nil
 		}
@@ -181,23 +444,23 @@ func (e *Executor) isUpToDateStatus(ctx context.Context, task string) (bool, err
 	return true, nil
 }
 
-func (e *Executor) isUpToDateTimestamp(ctx context.Context, task string) (bool, error) {
-	t := e.Tasks[task]
+func (e *Executor) isUpToDateTimestamp(ctx context.Context, call Call) (bool, error) {
+	t := e.Tasks[call.Task]
 
 	if len(t.Sources) == 0 || len(t.Generates) == 0 {
 		return false, nil
 	}
 
-	dir, err := e.getTaskDir(task)
+	dir, err := e.getTaskDir(call)
 	if err != nil {
 		return false, err
 	}
 
-	sources, err := e.ReplaceSliceVariables(task, t.Sources)
+	sources, err := e.ReplaceSliceVariables(call, t.Sources)
 	if err != nil {
 		return false, err
 	}
-	generates, err := e.ReplaceSliceVariables(task, t.Generates)
+	generates, err := e.ReplaceSliceVariables(call, t.Generates)
 	if err != nil {
 		return false, err
 	}
@@ -215,83 +478,166 @@ func (e *Executor) isUpToDateTimestamp(ctx context.Context, task string) (bool,
 	return generatesMinTime.After(sourcesMaxTime), nil
 }
 
-func (e *Executor) runCommand(ctx context.Context, task string, i int) error {
-	t := e.Tasks[task]
+// runFinally runs the finally commands of the task, all of them even if some
+// fail. They still run when ctx was stopped, but they are then killed after
+// GracePeriod. The first error is returned, along with the variables they set
+// with "set".
+func (e *Executor) runFinally(ctx context.Context, call Call, stdout, stderr io.Writer) (Vars, error) {
+	t := e.Tasks[call.Task]
+	if len(t.Finally) == 0 {
+		return nil, nil
+	}
 
-	c, err := e.ReplaceVariables(task, t.Cmds[i])
-	if err != nil {
-		return err
+	ctx, cancel := withFinallyGracePeriod(ctx, e.killContext(), e.GracePeriod)
+	defer cancel()
+
+	var (
+		set      Vars
+		firstErr error
+	)
+	for i, cmd := range t.Finally {
+		cmdSet, err := e.runCommand(ctx, call, cmd, stdout, stderr)
+		if err != nil {
+			if cmd.IgnoreError || t.IgnoreError {
+				fmt.Fprintf(stderr, "task: Ignored error of finally command %d of task \"%s\": %v\n", i+1, call.Task, err)
+				continue
+			}
+			if firstErr == nil {
+				firstErr = &TaskRunError{call.Task, len(t.Cmds) + i, err}
+			}
+			continue
+		}
+		set = mergeVars(set, cmdSet)
+		call = call.withSetVars(cmdSet)
 	}
+	return set, firstErr
+}
 
-	if strings.HasPrefix(c, "^") {
-		c = strings.TrimPrefix(c, "^")
-		if err = e.RunTask(ctx, c); err != nil {
-			return err
+// runCommand runs a command of the task of call, returning the variables it
+// set: the output of the command if the task has "set", or the variables set
+// by the task it calls
+func (e *Executor) runCommand(ctx context.Context, call Call, cmd *Cmd, stdout, stderr io.Writer) (Vars, error) {
+	t := e.Tasks[call.Task]
+
+	if err := stopContext(ctx).Err(); err != nil {
+		return nil, err
+	}
+	// finally commands aren't stopped, but they are killed too
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cmd.Task != "" {
+		cmdCall, err := e.getCall(call, cmd.Task, cmd.Vars)
+		if err != nil {
+			return nil, err
 		}
-		return nil
+		return e.runTask(ctx, cmdCall, stdout, stderr)
 	}
 
-	dir, err := e.getTaskDir(task)
+	c, err := e.ReplaceVariables(call, cmd.Cmd)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	envs, err := e.getEnviron(task)
+	if e.Dry {
+		fmt.Fprintln(stdout, c)
+		return nil, nil
+	}
+
+	dir, err := e.getTaskDir(call)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	envs, err := e.getEnviron(call)
+	if err != nil {
+		return nil, err
+	}
+	release, err := e.acquireConcurrency(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
+
 	opts := &execext.RunCommandOptions{
 		Context: ctx,
+		Stop:    stopContext(ctx),
 		Command: c,
 		Dir:     dir,
 		Env:     envs,
 		Stdin:   e.Stdin,
-		Stderr:  e.Stderr,
+		Stderr:  stderr,
 	}
 
 	if t.Set == "" {
-		e.println(c)
-		opts.Stdout = e.Stdout
-		if err = execext.RunCommand(opts); err != nil {
-			return err
-		}
-	} else {
-		buff := bytes.NewBuffer(nil)
-		opts.Stdout = buff
-		if err = execext.RunCommand(opts); err != nil {
-			return err
+		fmt.Fprintln(stdout, c)
+		opts.Stdout = stdout
+		return nil, execext.RunCommand(opts)
+	}
+
+	buff := bytes.NewBuffer(nil)
+	opts.Stdout = buff
+	if err = execext.RunCommand(opts); err != nil {
+		return nil, err
+	}
+	return Vars{t.Set: strings.TrimSpace(buff.String())}, nil
+}
+
+// acquireConcurrency waits until a command, including the status commands and
+// the ones of dynamic variables, can be run without going over the limit of
+// concurrent commands, returning a function that must be called when it
+// finishes
+func (e *Executor) acquireConcurrency(ctx context.Context) (release func(), err error) {
+	e.concurrencyOnce.Do(func() {
+		if e.Concurrency > 0 {
+			e.concurrencySemaphore = make(chan struct{}, e.Concurrency)
 		}
-		os.Setenv(t.Set, strings.TrimSpace(buff.String()))
+	})
+	if e.concurrencySemaphore == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case e.concurrencySemaphore <- struct{}{}:
+		return func() { <-e.concurrencySemaphore }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return nil
 }
 
-func (e *Executor) getTaskDir(name string) (string, error) {
-	t := e.Tasks[name]
+func (e *Executor) getTaskDir(call Call) (string, error) {
+	t := e.Tasks[call.Task]
 
-	exeDir, err := e.ReplaceVariables(name, e.Dir)
+	exeDir, err := e.ReplaceVariables(call, e.Dir)
 	if err != nil {
 		return "", err
 	}
-	taskDir, err := e.ReplaceVariables(name, t.Dir)
+	taskDir, err := e.ReplaceVariables(call, t.Dir)
 	if err != nil {
 		return "", err
 	}
+	if filepath.IsAbs(taskDir) {
+		return taskDir, nil
+	}
 
 	return filepath.Join(exeDir, taskDir), nil
 }
 
-func (e *Executor) getEnviron(task string) ([]string, error) {
-	t := e.Tasks[task]
+func (e *Executor) getEnviron(call Call) ([]string, error) {
+	t := e.Tasks[call.Task]
 
-	if t.Env == nil {
+	if t.Env == nil && len(call.setVars) == 0 {
 		return nil, nil
 	}
 
 	envs := os.Environ()
+	for k, v := range call.setVars {
+		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+	}
 
 	for k, v := range t.Env {
-		env, err := e.ReplaceVariables(task, fmt.Sprintf("%s=%s", k, v))
+		env, err := e.ReplaceVariables(call, fmt.Sprintf("%s=%s", k, v))
 		if err != nil {
 			return nil, err
 		}
@@ -2,13 +2,21 @@ package task_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-task/task"
+	"github.com/go-task/task/execext"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,6 +59,210 @@ func TestDeps(t *testing.T) {
 	}
 }
 
+func TestRunOnce(t *testing.T) {
+	const dir = "testdata/run_once"
+	var file = filepath.Join(dir, "generated.txt")
+
+	_ = os.Remove(file)
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default", "a"))
+
+	d, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "generated\n", string(d))
+
+	_ = os.Remove(file)
+	assert.NoError(t, e.Run("regenerate"))
+	d, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "generated\ngenerated\n", string(d))
+}
+
+func TestDry(t *testing.T) {
+	const dir = "testdata/run_once"
+	var file = filepath.Join(dir, "generated.txt")
+
+	_ = os.Remove(file)
+
+	buff := bytes.NewBuffer(nil)
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+		Dry:    true,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	assert.Equal(t, "echo generated >> generated.txt\n", buff.String())
+	if _, err := os.Stat(file); err == nil {
+		t.Errorf("File %s should not exist on dry mode", file)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	const dir = "testdata/concurrency"
+
+	_ = os.Remove(filepath.Join(dir, "lock"))
+
+	e := &task.Executor{
+		Dir:         dir,
+		Stdout:      ioutil.Discard,
+		Stderr:      ioutil.Discard,
+		Concurrency: 1,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	// status commands and dynamic variables are limited too
+	_ = os.Remove(filepath.Join(dir, "overlap.txt"))
+	assert.NoError(t, e.Run("status"))
+	_, err := os.Stat(filepath.Join(dir, "overlap.txt"))
+	assert.True(t, os.IsNotExist(err), "status commands ran at the same time")
+	assert.NoError(t, e.Run("dynamic"))
+}
+
+func TestKeepGoing(t *testing.T) {
+	const dir = "testdata/keep_going"
+
+	files := []string{"default.txt", "ok.txt", "other.txt", "bad-dep.txt"}
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f))
+	}
+
+	e := &task.Executor{
+		Dir:       dir,
+		Stdout:    ioutil.Discard,
+		Stderr:    ioutil.Discard,
+		KeepGoing: true,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	err := e.Run("default", "other")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"fail"`)
+
+	_, err = os.Stat(filepath.Join(dir, "default.txt"))
+	assert.True(t, os.IsNotExist(err), "default.txt should not exist")
+	for _, f := range []string{"ok.txt", "other.txt"} {
+		_, err = os.Stat(filepath.Join(dir, f))
+		assert.NoError(t, err, "%s should exist", f)
+	}
+
+	// a dep that can't even be called is a failure too
+	err = e.Run("bad-dep")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no such dep")
+	_, err = os.Stat(filepath.Join(dir, "bad-dep.txt"))
+	assert.True(t, os.IsNotExist(err), "bad-dep.txt should not exist")
+}
+
+func TestIgnoreError(t *testing.T) {
+	const dir = "testdata/ignore_error"
+
+	for _, f := range []string{"cmd.txt", "task.txt", "fail.txt"} {
+		_ = os.Remove(filepath.Join(dir, f))
+	}
+
+	var buff bytes.Buffer
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: &buff,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("cmd", "task"))
+	assert.Contains(t, buff.String(), `task: Ignored error of command 1 of task "cmd"`)
+	assert.Error(t, e.Run("fail"))
+
+	for _, f := range []string{"cmd.txt", "task.txt"} {
+		_, err := os.Stat(filepath.Join(dir, f))
+		assert.NoError(t, err, "%s should exist", f)
+	}
+	_, err := os.Stat(filepath.Join(dir, "fail.txt"))
+	assert.True(t, os.IsNotExist(err), "fail.txt should not exist")
+}
+
+func TestFinally(t *testing.T) {
+	const dir = "testdata/finally"
+
+	files := []string{"one.txt", "two.txt", "cleanup.txt", "ok.txt", "ok-cleanup.txt"}
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f))
+	}
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("ok"))
+	err := e.Run("fail")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exit status 3", "the error of the commands should be kept")
+
+	for _, f := range []string{"one.txt", "cleanup.txt", "ok.txt", "ok-cleanup.txt"} {
+		_, err = os.Stat(filepath.Join(dir, f))
+		assert.NoError(t, err, "%s should exist", f)
+	}
+	_, err = os.Stat(filepath.Join(dir, "two.txt"))
+	assert.True(t, os.IsNotExist(err), "two.txt should not exist")
+}
+
+func TestExitCode(t *testing.T) {
+	e := &task.Executor{
+		Dir:    "testdata/exit_code",
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	for _, name := range []string{"exit", "call", "dep"} {
+		assert.Equal(t, 3, task.ExitCode(e.Run(name)), name)
+	}
+	assert.Equal(t, 0, task.ExitCode(e.Run("ignored")))
+	assert.Equal(t, 1, task.ExitCode(e.Run("missing")))
+}
+
+func TestErrors(t *testing.T) {
+	e := &task.Executor{
+		Dir:    "testdata",
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	var taskfileErr *task.TaskfileNotFoundError
+	if assert.True(t, errors.As(e.ReadTaskfile(), &taskfileErr)) {
+		assert.Equal(t, filepath.Join("testdata", "Taskfile"), taskfileErr.Path())
+	}
+
+	e.Dir = "testdata/finally"
+	assert.NoError(t, e.ReadTaskfile())
+
+	var notFoundErr *task.TaskNotFoundError
+	if assert.True(t, errors.As(e.Run("missing"), &notFoundErr)) {
+		assert.Equal(t, "missing", notFoundErr.TaskName())
+	}
+
+	var (
+		runErr  *task.TaskRunError
+		exitErr *execext.ExitError
+	)
+	err := e.Run("fail")
+	if assert.True(t, errors.As(err, &runErr)) {
+		assert.Equal(t, "fail", runErr.TaskName())
+		assert.Equal(t, 1, runErr.CommandIndex())
+	}
+	if assert.True(t, errors.As(err, &exitErr)) {
+		assert.Equal(t, 3, exitErr.Status)
+	}
+}
+
 func TestVars(t *testing.T) {
 	const dir = "testdata/vars"
 
@@ -91,6 +303,93 @@ func TestVars(t *testing.T) {
 	}
 }
 
+func TestParams(t *testing.T) {
+	const dir = "testdata/params"
+
+	files := []struct {
+		file    string
+		content string
+	}{
+		{"dep1.txt", "dep1"},
+		{"dep2.txt", "dep2"},
+		{"call.txt", "from-caller"},
+		{"default.txt", "default"},
+	}
+
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f.file))
+	}
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	for _, f := range files {
+		d, err := ioutil.ReadFile(filepath.Join(dir, f.file))
+		if err != nil {
+			t.Errorf("Error reading %s: %v", f.file, err)
+		}
+		assert.Equal(t, f.content, strings.TrimSpace(string(d)))
+	}
+}
+
+func TestTaskfileVersion2(t *testing.T) {
+	const dir = "testdata/version2"
+
+	files := []struct {
+		file    string
+		content string
+	}{
+		{"foo.txt", "foo"},
+		{"bar.txt", "bar"},
+		{"baz.txt", "baz"},
+	}
+
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f.file))
+	}
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	for _, f := range files {
+		d, err := ioutil.ReadFile(filepath.Join(dir, f.file))
+		if err != nil {
+			t.Errorf("Error reading %s: %v", f.file, err)
+		}
+		assert.Equal(t, f.content, strings.TrimSpace(string(d)))
+	}
+}
+
+func TestCommandLineVars(t *testing.T) {
+	const dir = "testdata/vars"
+	var file = filepath.Join(dir, "foo.txt")
+
+	_ = os.Remove(file)
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+		Vars:   task.Vars{"FOO": "from-command-line"},
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	d, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-command-line", strings.TrimSpace(string(d)))
+}
+
 func TestTaskCall(t *testing.T) {
 	const dir = "testdata/task_call"
 
@@ -116,6 +415,39 @@ func TestTaskCall(t *testing.T) {
 			t.Error(err)
 		}
 	}
+	// variables set by tasks must not leak into the process environment
+	if v := os.Getenv("FILE"); v != "" {
+		t.Errorf("FILE should not be in the environment but is %s", v)
+	}
+}
+
+func TestSetVars(t *testing.T) {
+	const dir = "testdata/set_vars"
+
+	var (
+		dflt    = filepath.Join(dir, "default.txt")
+		sibling = filepath.Join(dir, "sibling.txt")
+	)
+	_ = os.Remove(dflt)
+	_ = os.Remove(sibling)
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	// the task depending on the setter sees the variable
+	d, err := ioutil.ReadFile(dflt)
+	assert.NoError(t, err)
+	assert.Equal(t, "set set", strings.TrimSpace(string(d)))
+
+	// the dependency running concurrently with it doesn't
+	d, err = ioutil.ReadFile(sibling)
+	assert.NoError(t, err)
+	assert.Equal(t, "unset unset", strings.TrimSpace(string(d)))
 }
 
 func TestStatus(t *testing.T) {
@@ -149,6 +481,373 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+func TestIgnoredSources(t *testing.T) {
+	const dir = "testdata/ignore_sources"
+	var (
+		source  = filepath.Join(dir, "src", "a", "source.txt")
+		ignored = filepath.Join(dir, "src", "ignored", "source.txt")
+	)
+
+	_ = os.RemoveAll(filepath.Join(dir, "src"))
+	_ = os.RemoveAll(filepath.Join(dir, task.StateDirPath))
+	_ = os.Remove(filepath.Join(dir, "timestamp.txt"))
+	_ = os.Remove(filepath.Join(dir, "checksum.txt"))
+	for _, f := range []string{source, ignored} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0755))
+		assert.NoError(t, ioutil.WriteFile(f, []byte("foo"), 0644))
+	}
+
+	buff := bytes.NewBuffer(nil)
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	for _, name := range []string{"timestamp", "checksum"} {
+		upToDate := fmt.Sprintf(`task: Task "%s" is up to date`, name) + "\n"
+
+		assert.NoError(t, e.Run(name))
+		buff.Reset()
+		assert.NoError(t, e.Run(name))
+		assert.Equal(t, upToDate, buff.String(), name)
+
+		// changing an ignored file keeps the task up to date
+		future := time.Now().Add(time.Hour)
+		assert.NoError(t, ioutil.WriteFile(ignored, []byte(name), 0644))
+		assert.NoError(t, os.Chtimes(ignored, future, future))
+		buff.Reset()
+		assert.NoError(t, e.Run(name))
+		assert.Equal(t, upToDate, buff.String(), name)
+
+		assert.NoError(t, ioutil.WriteFile(source, []byte(name), 0644))
+		assert.NoError(t, os.Chtimes(source, future, future))
+		buff.Reset()
+		assert.NoError(t, e.Run(name))
+		assert.NotEqual(t, upToDate, buff.String(), name)
+	}
+}
+
+// syncBuffer is a buffer safe for the concurrent writes of tasks run at the
+// same time
+type syncBuffer struct {
+	mutex sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func TestChecksum(t *testing.T) {
+	const dir = "testdata/checksum"
+	var (
+		source    = filepath.Join(dir, "source.txt")
+		generated = filepath.Join(dir, "generated.txt")
+		upToDate  = `task: Task "build" is up to date` + "\n"
+	)
+
+	_ = os.RemoveAll(filepath.Join(dir, task.StateDirPath))
+	_ = os.Remove(generated)
+	assert.NoError(t, ioutil.WriteFile(source, []byte("foo"), 0644))
+
+	buff := &syncBuffer{}
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	assert.NoError(t, e.Run("build"))
+	assert.NotEqual(t, upToDate, buff.String())
+	_, err := os.Stat(generated)
+	assert.NoError(t, err)
+
+	buff.Reset()
+	assert.NoError(t, e.Run("build"))
+	assert.Equal(t, upToDate, buff.String())
+
+	// a new modification time with the same content is still up to date
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(source, future, future))
+	buff.Reset()
+	assert.NoError(t, e.Run("build"))
+	assert.Equal(t, upToDate, buff.String())
+
+	assert.NoError(t, ioutil.WriteFile(source, []byte("bar"), 0644))
+	buff.Reset()
+	assert.NoError(t, e.Run("build"))
+	assert.NotEqual(t, upToDate, buff.String())
+	// sources that don't exist aren't an error, but the task is never up to
+	// date without any
+	for i := 0; i < 2; i++ {
+		buff.Reset()
+		assert.NoError(t, e.Run("missing"))
+		assert.Equal(t, "echo missing\nmissing\n", buff.String())
+	}
+	// each call of a task has its own checksum
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0644))
+	assert.NoError(t, e.Run("params"))
+	buff.Reset()
+	assert.NoError(t, e.Run("params"))
+	assert.Equal(t, 2, strings.Count(buff.String(), `task: Task "param" is up to date`))
+}
+
+func TestIncludes(t *testing.T) {
+	const dir = "testdata/includes"
+
+	files := []struct {
+		file    string
+		content string
+	}{
+		{"root.txt", "root"},
+		{"shared.txt", "shared"},
+		{"services/api/build.txt", "api"},
+		{"services/api/generated.txt", "api"},
+		{"services/web/build.txt", "web"},
+	}
+
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f.file))
+	}
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.Contains(t, e.Tasks, "api:generate")
+	assert.NoError(t, e.Run("default"))
+
+	for _, f := range files {
+		d, err := ioutil.ReadFile(filepath.Join(dir, f.file))
+		if err != nil {
+			t.Errorf("Error reading %s: %v", f.file, err)
+		}
+		assert.Equal(t, f.content, strings.TrimSpace(string(d)))
+	}
+}
+
+func TestIncludesLegacy(t *testing.T) {
+	const dir = "testdata/includes_legacy"
+	var file = filepath.Join(dir, "includes.txt")
+
+	_ = os.Remove(file)
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Validate())
+	assert.NoError(t, e.Run("includes"))
+
+	d, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "includes\n", string(d))
+}
+
+func TestValidate(t *testing.T) {
+	const dir = "testdata/validate"
+
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	err := e.Validate()
+	if !assert.Error(t, err) {
+		return
+	}
+	for _, msg := range []string{
+		`testdata/validate/Taskfile.yml:5: unknown key "tasks.build.cmd"`,
+		`testdata/validate/Taskfile.yml:10: unknown key "tasks.build.cmds.ignore"`,
+		`testdata/validate/Taskfile.yml:13: unknown key "tasks.test.dependencies"`,
+		`Invalid template in "cmds[0]" of task "build"`,
+		`undefined task "missing"`,
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	e = &task.Executor{
+		Dir:    "testdata/version2",
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Validate())
+}
+
+func TestListTasks(t *testing.T) {
+	const dir = "testdata/status"
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo.txt"), nil, 0644))
+
+	buff := bytes.NewBuffer(nil)
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	assert.NoError(t, e.ListTasks(false, false))
+	assert.Empty(t, buff.String(), "Tasks without description should not be listed")
+
+	assert.NoError(t, e.ListTasks(true, false))
+	assert.Equal(t, "- gen-foo\n", buff.String())
+
+	buff.Reset()
+	assert.NoError(t, e.ListTasks(true, true))
+	var infos []*task.TaskInfo
+	assert.NoError(t, json.Unmarshal(buff.Bytes(), &infos))
+	assert.Equal(t, []*task.TaskInfo{{
+		Name:      "gen-foo",
+		Deps:      []string{},
+		Sources:   []string{},
+		Generates: []string{},
+		Dir:       dir,
+		UpToDate:  true,
+	}}, infos)
+
+	// the output defaults to the one of the process
+	e = &task.Executor{Dir: dir}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.ListTasks(false, false))
+	assert.Equal(t, os.Stdout, e.Stdout)
+}
+
+func TestOutput(t *testing.T) {
+	const dir = "testdata/output"
+
+	buff := bytes.NewBuffer(nil)
+	e := &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.Equal(t, task.OutputPrefixed, e.Output)
+	assert.NoError(t, e.Run("default"))
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	assert.Len(t, lines, 6)
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "[foo] ") && !strings.HasPrefix(l, "[bar] ") {
+			t.Errorf("Line should be prefixed by the task name: %s", l)
+		}
+	}
+
+	buff.Reset()
+	e = &task.Executor{
+		Dir:    dir,
+		Stdout: buff,
+		Stderr: buff,
+		Output: task.OutputGroup,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+	assert.NoError(t, e.Run("default"))
+
+	fooOutput := "echo foo1; echo foo2\nfoo1\nfoo2\n"
+	barOutput := "echo bar1; echo bar2\nbar1\nbar2\n"
+	if s := buff.String(); s != fooOutput+barOutput && s != barOutput+fooOutput {
+		t.Errorf("Output of each task should be grouped, but is:\n%s", s)
+	}
+
+	// the output of a called task is part of the caller's
+	buff.Reset()
+	assert.NoError(t, e.Run("nested"))
+	assert.Equal(t, "echo first\nfirst\necho middle\nmiddle\necho last\nlast\n", buff.String())
+
+	e.Output = "unknown"
+	assert.Error(t, e.Run("default"))
+}
+
+func TestCancel(t *testing.T) {
+	const dir = "testdata/cancel"
+
+	// kill makes Kill be called right after canceling
+	run := func(taskName string, gracePeriod time.Duration, kill bool) (time.Duration, error) {
+		for _, f := range []string{"started.txt", "after.txt", "cleanup.txt"} {
+			_ = os.Remove(filepath.Join(dir, f))
+		}
+
+		e := &task.Executor{
+			Dir:         dir,
+			GracePeriod: gracePeriod,
+			Stdout:      ioutil.Discard,
+			Stderr:      ioutil.Discard,
+		}
+		assert.NoError(t, e.ReadTaskfile())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan error)
+		go func() {
+			done <- e.RunContext(ctx, taskName)
+		}()
+
+		for i := 0; i < 50; i++ {
+			if _, err := os.Stat(filepath.Join(dir, "started.txt")); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		start := time.Now()
+		cancel()
+		if kill {
+			e.Kill()
+		}
+		select {
+		case err := <-done:
+			return time.Since(start), err
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: the task didn't stop when the context was canceled", taskName)
+			return 0, nil
+		}
+	}
+
+	// the command and its child exit on SIGTERM, without waiting for the
+	// grace period
+	elapsed, err := run("default", 5*time.Second, false)
+	assert.Error(t, err)
+	assert.True(t, elapsed < 5*time.Second, "stopped after %v", elapsed)
+	_, err = os.Stat(filepath.Join(dir, "after.txt"))
+	assert.True(t, os.IsNotExist(err), "no command should start once canceled")
+	_, err = os.Stat(filepath.Join(dir, "cleanup.txt"))
+	assert.NoError(t, err, "finally commands should still run")
+
+	// the ones ignoring it are killed after the grace period
+	elapsed, err = run("stubborn", 500*time.Millisecond, false)
+	assert.Error(t, err)
+	assert.True(t, elapsed >= 500*time.Millisecond && elapsed < 5*time.Second, "stopped after %v", elapsed)
+	_, err = os.Stat(filepath.Join(dir, "after.txt"))
+	assert.True(t, os.IsNotExist(err), "no command should start once canceled")
+	_, err = os.Stat(filepath.Join(dir, "cleanup.txt"))
+	assert.NoError(t, err, "finally commands should still run")
+
+	// finally commands are killed after the grace period too, and the ones
+	// after them aren't run
+	elapsed, err = run("hung-finally", 500*time.Millisecond, false)
+	assert.Error(t, err)
+	assert.True(t, elapsed >= 500*time.Millisecond && elapsed < 5*time.Second, "stopped after %v", elapsed)
+	_, err = os.Stat(filepath.Join(dir, "cleanup.txt"))
+	assert.True(t, os.IsNotExist(err), "no finally command should start once killed")
+
+	// Kill doesn't wait for the grace period
+	elapsed, err = run("hung-finally", time.Minute, true)
+	assert.Error(t, err)
+	assert.True(t, elapsed < 5*time.Second, "stopped after %v", elapsed)
+}
+
 func TestInit(t *testing.T) {
 	const dir = "testdata/init"
 	var file = filepath.Join(dir, "Taskfile.yml")
@@ -166,3 +865,135 @@ func TestInit(t *testing.T) {
 		t.Errorf("Taskfile.yml should exists")
 	}
 }
+
+// watchTest runs a task of testdata/watch in watch mode, counting its runs by
+// the lines it appends to a file
+type watchTest struct {
+	t      *testing.T
+	out    string
+	cancel context.CancelFunc
+	done   chan error
+}
+
+func startWatch(t *testing.T, output, taskName, out string) *watchTest {
+	const dir = "testdata/watch"
+
+	w := &watchTest{
+		t:    t,
+		out:  filepath.Join(dir, out),
+		done: make(chan error),
+	}
+	_ = os.Remove(w.out)
+
+	e := &task.Executor{
+		Dir:         dir,
+		Watch:       true,
+		Output:      output,
+		GracePeriod: 10 * time.Second,
+		Stdout:      ioutil.Discard,
+		Stderr:      ioutil.Discard,
+	}
+	assert.NoError(t, e.ReadTaskfile())
+
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(context.Background())
+	go func() {
+		w.done <- e.RunContext(ctx, taskName)
+	}()
+	return w
+}
+
+func (w *watchTest) runs() int {
+	b, _ := ioutil.ReadFile(w.out)
+	return strings.Count(string(b), "run")
+}
+
+// waitRuns waits for the task to have run n times
+func (w *watchTest) waitRuns(n int) {
+	for i := 0; i < 50 && w.runs() < n; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(w.t, n, w.runs())
+}
+
+// assertNoMoreRuns checks that the task doesn't run more than n times, for
+// long enough that a change would have been handled
+func (w *watchTest) assertNoMoreRuns(n int) {
+	deadline := time.Now().Add(3 * task.WatchDebounce)
+	for time.Now().Before(deadline) && w.runs() <= n {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(w.t, n, w.runs())
+}
+
+func (w *watchTest) stop() {
+	w.cancel()
+	select {
+	case err := <-w.done:
+		assert.NoError(w.t, err)
+	case <-time.After(5 * time.Second):
+		w.t.Error("watch didn't stop when the context was canceled")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	src := filepath.Join("testdata/watch", "src.txt")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("0"), 0644))
+
+	w := startWatch(t, "", "default", "out.txt")
+	defer w.stop()
+	w.waitRuns(1)
+
+	// many changes at once run the task only once
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, ioutil.WriteFile(src, []byte(strconv.Itoa(i)), 0644))
+	}
+	w.waitRuns(2)
+	w.assertNoMoreRuns(2)
+}
+
+func TestWatchRestart(t *testing.T) {
+	var (
+		src     = filepath.Join("testdata/watch", "src.txt")
+		stopped = filepath.Join("testdata/watch", "serve-stopped.txt")
+	)
+
+	for _, output := range []string{task.OutputInterleaved, task.OutputPrefixed, task.OutputGroup} {
+		_ = os.Remove(stopped)
+		assert.NoError(t, ioutil.WriteFile(src, []byte("0"), 0644))
+
+		w := startWatch(t, output, "serve", "serve.txt")
+		w.waitRuns(1)
+
+		// the task never finishes, so it must be stopped to run again, with
+		// the processes it started, well before the grace period is over
+		assert.NoError(t, ioutil.WriteFile(src, []byte("1"), 0644))
+		w.waitRuns(2)
+
+		// it was asked to exit with SIGTERM, rather than killed
+		b, err := ioutil.ReadFile(stopped)
+		assert.NoError(t, err, output)
+		assert.Equal(t, "stopped\n", string(b), output)
+		w.stop()
+	}
+}
+
+func TestWatchNewDirs(t *testing.T) {
+	deep := filepath.Join("testdata/watch", "deep")
+	_ = os.RemoveAll(deep)
+	assert.NoError(t, os.MkdirAll(filepath.Join(deep, "ignored"), 0755))
+
+	w := startWatch(t, "", "deep", "deep.txt")
+	defer w.stop()
+	w.waitRuns(1)
+
+	// ignored files don't run the task
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(deep, "ignored", "a.txt"), []byte("a"), 0644))
+	w.assertNoMoreRuns(1)
+
+	// files in new directories do, even if they are created along with them
+	newDir := filepath.Join(deep, "new", "sub")
+	assert.NoError(t, os.MkdirAll(newDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(newDir, "a.txt"), []byte("a"), 0644))
+	w.waitRuns(2)
+}
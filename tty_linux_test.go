@@ -0,0 +1,99 @@
+package task_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-task/task"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTerminal runs a task reading the terminal, in a helper process that has
+// a pseudo terminal as input and controlling terminal
+func TestTerminal(t *testing.T) {
+	const dir = "testdata/tty"
+	var file = filepath.Join(dir, "line.txt")
+
+	if os.Getenv("TASK_TEST_TTY_HELPER") != "" {
+		e := &task.Executor{
+			Dir:    dir,
+			Stdout: ioutil.Discard,
+			Stderr: ioutil.Discard,
+		}
+		assert.NoError(t, e.ReadTaskfile())
+		assert.NoError(t, e.Run("default"))
+		return
+	}
+
+	_ = os.Remove(file)
+
+	master, slave, err := openPty()
+	if err != nil {
+		t.Skipf("no pseudo terminal: %v", err)
+	}
+	defer master.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTerminal$")
+	cmd.Env = append(os.Environ(), "TASK_TEST_TTY_HELPER=1")
+	cmd.Stdin = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	assert.NoError(t, cmd.Start())
+	slave.Close()
+
+	// echoed back by the terminal, so it must be read
+	go func() { _, _ = ioutil.ReadAll(master) }()
+	_, err = master.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("the command reading the terminal hung")
+	}
+
+	d, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(d))
+}
+
+// openPty opens a new pseudo terminal, returning its master and slave sides
+func openPty() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		unlock int32
+		n      uint32
+	)
+	if err = ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+		err = ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n))
+	}
+	if err == nil {
+		slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
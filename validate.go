@@ -0,0 +1,291 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+var yamlKeyRegexp = regexp.MustCompile(`^(\s*)(-\s+)?("[^"]*"|'[^']*'|[^\s#'"{\[-][^:#]*?)\s*:(\s|$)`)
+
+// Validate checks the Taskfiles read by ReadTaskfile for unknown keys, the
+// templates of the tasks for syntax errors, and the deps and calls of the tasks
+// for cycles and undefined tasks. It returns all the problems found at once.
+func (e *Executor) Validate() error {
+	var errs []error
+
+	for _, f := range e.taskfiles {
+		fileErrs, err := checkTaskfileKeys(f)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, fileErrs...)
+	}
+
+	names := make([]string, 0, len(e.Tasks))
+	for name := range e.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		errs = append(errs, checkTaskTemplates(name, e.Tasks[name])...)
+
+		switch method := e.Tasks[name].Method; method {
+		case "", methodTimestamp, methodChecksum:
+		default:
+			errs = append(errs, &invalidMethodError{name, method})
+		}
+	}
+
+	if err := e.CheckCyclicDep(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return &validationError{errs}
+	}
+	return nil
+}
+
+// checkTaskTemplates parses all the templates of a task
+func checkTaskTemplates(name string, t *Task) (errs []error) {
+	check := func(field, s string) {
+		if _, err := template.New("").Funcs(templateFuncs).Parse(s); err != nil {
+			errs = append(errs, &templateError{name, field, err})
+		}
+	}
+	checkSlice := func(field string, s []string) {
+		for i, v := range s {
+			check(fmt.Sprintf("%s[%d]", field, i), v)
+		}
+	}
+	checkVars := func(field string, vars Vars) {
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			check(fmt.Sprintf("%s.%s", field, k), vars[k])
+		}
+	}
+
+	checkCmds := func(field string, cmds []*Cmd) {
+		for i, c := range cmds {
+			if c.Task != "" {
+				check(fmt.Sprintf("%s[%d].task", field, i), c.Task)
+			} else {
+				check(fmt.Sprintf("%s[%d]", field, i), c.Cmd)
+			}
+			checkVars(fmt.Sprintf("%s[%d].vars", field, i), c.Vars)
+		}
+	}
+
+	checkCmds("cmds", t.Cmds)
+	checkCmds("finally", t.Finally)
+	for i, d := range t.Deps {
+		check(fmt.Sprintf("deps[%d]", i), d.Task)
+		checkVars(fmt.Sprintf("deps[%d].vars", i), d.Vars)
+	}
+	checkSlice("sources", t.Sources)
+	checkSlice("generates", t.Generates)
+	checkSlice("status", t.Status)
+	check("dir", t.Dir)
+	checkVars("vars", t.Vars)
+	checkVars("env", t.Env)
+	return
+}
+
+// checkTaskfileKeys returns an error for each unknown key in a Taskfile. The
+// errors of YAML Taskfiles include the line of the key.
+func checkTaskfileKeys(file string) ([]error, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		c         = &keyChecker{file: file}
+		ext       = filepath.Ext(file)
+		unmarshal = func(v interface{}) error {
+			return taskfileUnmarshaler[ext](b, v)
+		}
+		data interface{}
+	)
+	if ext == ".yml" {
+		var m yaml.MapSlice
+		err = unmarshal(&m)
+		data = m
+		c.lines = getYAMLKeyLines(b)
+	} else {
+		err = unmarshal(&data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := getTaskfileVersion(unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == "" {
+		for _, item := range mapItems(data) {
+			c.checkTask([]string{item.key}, item.value)
+		}
+		return c.errs, nil
+	}
+
+	c.checkKeys(nil, data, taskfileKeys)
+	for _, item := range mapItems(data) {
+		if item.key != "tasks" {
+			continue
+		}
+		for _, task := range mapItems(item.value) {
+			c.checkTask([]string{"tasks", task.key}, task.value)
+		}
+	}
+	return c.errs, nil
+}
+
+var (
+	taskfileKeys = structKeys(Taskfile{})
+	taskKeys     = structKeys(Task{})
+	cmdKeys      = structKeys(Cmd{})
+	depKeys      = structKeys(Dep{})
+)
+
+// structKeys returns the keys of the fields of a struct, as decoded from YAML
+func structKeys(v interface{}) map[string]struct{} {
+	t := reflect.TypeOf(v)
+	keys := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := strings.ToLower(f.Name)
+		if tag := strings.Split(f.Tag.Get("yaml"), ",")[0]; tag != "" {
+			key = tag
+		}
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+type keyChecker struct {
+	file  string
+	lines map[string][]int
+	errs  []error
+}
+
+func (c *keyChecker) checkTask(path []string, v interface{}) {
+	c.checkKeys(path, v, taskKeys)
+
+	for _, item := range mapItems(v) {
+		var keys map[string]struct{}
+		switch item.key {
+		case "cmds", "finally":
+			keys = cmdKeys
+		case "deps":
+			keys = depKeys
+		default:
+			continue
+		}
+		list := reflect.ValueOf(item.value)
+		if list.Kind() != reflect.Slice {
+			continue
+		}
+		for i := 0; i < list.Len(); i++ {
+			c.checkKeys(append(path, item.key), list.Index(i).Interface(), keys)
+		}
+	}
+}
+
+func (c *keyChecker) checkKeys(path []string, v interface{}, keys map[string]struct{}) {
+	for _, item := range mapItems(v) {
+		if _, ok := keys[item.key]; ok {
+			continue
+		}
+
+		keyPath := append(append([]string{}, path...), item.key)
+		err := &unknownKeyError{file: c.file, path: keyPath}
+
+		lineKey := strings.Join(keyPath, "\x00")
+		if lines := c.lines[lineKey]; len(lines) > 0 {
+			err.line = lines[0]
+			c.lines[lineKey] = lines[1:]
+		}
+		c.errs = append(c.errs, err)
+	}
+}
+
+type mapItem struct {
+	key   string
+	value interface{}
+}
+
+// mapItems returns the items of a map decoded from any of the Taskfile
+// formats, in the order of the file for YAML and sorted otherwise
+func mapItems(v interface{}) []mapItem {
+	var items []mapItem
+	switch m := v.(type) {
+	case yaml.MapSlice:
+		for _, item := range m {
+			items = append(items, mapItem{fmt.Sprint(item.Key), item.Value})
+		}
+		return items
+	case map[string]interface{}:
+		for k, v := range m {
+			items = append(items, mapItem{k, v})
+		}
+	default:
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+	return items
+}
+
+// getYAMLKeyLines returns the lines where each path of keys is found in a
+// YAML document, in order. It only understands block mappings and sequences,
+// which are the common way to write a Taskfile.
+func getYAMLKeyLines(b []byte) map[string][]int {
+	type level struct {
+		indent int
+		key    string
+	}
+
+	var (
+		lines = make(map[string][]int)
+		stack []level
+		s     = bufio.NewScanner(bytes.NewReader(b))
+	)
+	for n := 1; s.Scan(); n++ {
+		m := yamlKeyRegexp.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+		indent := len(m[1]) + len(m[2])
+		key := strings.Trim(m[3], `"'`)
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, level{indent, key})
+
+		path := make([]string, len(stack))
+		for i, l := range stack {
+			path[i] = l.key
+		}
+		lineKey := strings.Join(path, "\x00")
+		lines[lineKey] = append(lines[lineKey], n)
+	}
+	return lines
+}
@@ -2,8 +2,10 @@ package task
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -23,8 +25,29 @@ var (
 	TaskvarsFilePath = "Taskvars"
 	// ErrMultilineResultCmd is returned when a command returns multiline result
 	ErrMultilineResultCmd = errors.New("Got multiline result from command")
+	// ErrCantUnmarshalVars is returned for invalid vars TOML
+	ErrCantUnmarshalVars = errors.New("task: can't unmarshal vars value")
 )
 
+// Vars is a string map of variables
+type Vars map[string]string
+
+// tomlVars converts a TOML table, as given to toml.Unmarshaler, to Vars
+func tomlVars(v interface{}) (Vars, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrCantUnmarshalVars
+	}
+	vars := make(Vars, len(m))
+	for k, v := range m {
+		vars[k] = fmt.Sprint(v)
+	}
+	return vars, nil
+}
+
 func (e *Executor) handleDynamicVariableContent(value string) (string, error) {
 	if !strings.HasPrefix(value, "$") {
 		return value, nil
@@ -38,7 +61,13 @@ func (e *Executor) handleDynamicVariableContent(value string) (string, error) {
 		Stdout:  buff,
 		Stderr:  e.Stderr,
 	}
-	if err := execext.RunCommand(opts); err != nil {
+	release, err := e.acquireConcurrency(context.Background())
+	if err != nil {
+		return "", err
+	}
+	err = execext.RunCommand(opts)
+	release()
+	if err != nil {
 		return "", err
 	}
 
@@ -52,8 +81,8 @@ func (e *Executor) handleDynamicVariableContent(value string) (string, error) {
 	return result, nil
 }
 
-func (e *Executor) getVariables(task string) (map[string]string, error) {
-	t := e.Tasks[task]
+func (e *Executor) getVariables(call Call) (map[string]string, error) {
+	t := e.Tasks[call.Task]
 
 	localVariables := make(map[string]string)
 	for key, value := range t.Vars {
@@ -77,6 +106,19 @@ func (e *Executor) getVariables(task string) (map[string]string, error) {
 	for key, value := range getEnvironmentVariables() {
 		localVariables[key] = value
 	}
+	for key, value := range call.setVars {
+		localVariables[key] = value
+	}
+	for key, value := range call.Vars {
+		val, err := e.handleDynamicVariableContent(value)
+		if err != nil {
+			return nil, err
+		}
+		localVariables[key] = val
+	}
+	for key, value := range e.Vars {
+		localVariables[key] = value
+	}
 	return localVariables, nil
 }
 
@@ -109,11 +151,11 @@ func init() {
 }
 
 // ReplaceSliceVariables writes vars into initial string slice
-func (e *Executor) ReplaceSliceVariables(task string, initials []string) ([]string, error) {
+func (e *Executor) ReplaceSliceVariables(call Call, initials []string) ([]string, error) {
 	result := make([]string, len(initials))
 	for i, s := range initials {
 		var err error
-		result[i], err = e.ReplaceVariables(task, s)
+		result[i], err = e.ReplaceVariables(call, s)
 		if err != nil {
 			return nil, err
 		}
@@ -122,8 +164,8 @@ func (e *Executor) ReplaceSliceVariables(task string, initials []string) ([]stri
 }
 
 // ReplaceVariables writes vars into initial string
-func (e *Executor) ReplaceVariables(task, initial string) (string, error) {
-	vars, err := e.getVariables(task)
+func (e *Executor) ReplaceVariables(call Call, initial string) (string, error) {
+	vars, err := e.getVariables(call)
 	if err != nil {
 		return "", err
 	}
@@ -140,6 +182,23 @@ func (e *Executor) ReplaceVariables(task, initial string) (string, error) {
 	return b.String(), nil
 }
 
+// replaceCallVariables writes vars into the values of the variables given by
+// the caller to another task
+func (e *Executor) replaceCallVariables(call Call, vars Vars) (Vars, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	result := make(Vars, len(vars))
+	for k, v := range vars {
+		var err error
+		result[k], err = e.ReplaceVariables(call, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 // GetEnvironmentVariables returns environment variables as map
 func getEnvironmentVariables() map[string]string {
 	var (
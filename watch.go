@@ -2,24 +2,21 @@ package task
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/mattn/go-zglob"
 )
 
-// watchTasks start watching the given tasks
-func (e *Executor) watchTasks(args ...string) error {
-	e.printfln("task: Started watching for tasks: %s", strings.Join(args, ", "))
+// watchDebounce is how long to wait for more events after a change, so that
+// the many events of a single save run the tasks only once
+const watchDebounce = 200 * time.Millisecond
 
-	// run tasks on init
-	for _, a := range args {
-		if err := e.RunTask(context.Background(), a); err != nil {
-			e.println(err)
-			break
-		}
-	}
+// watchTasks start watching the given tasks, until ctx is canceled
+func (e *Executor) watchTasks(ctx context.Context, args ...string) error {
+	e.printfln("task: Started watching for tasks: %s", strings.Join(args, ", "))
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -27,69 +24,231 @@ func (e *Executor) watchTasks(args ...string) error {
 	}
 	defer watcher.Close()
 
-	go func() {
-		for {
-			if err := e.registerWatchedFiles(watcher, args); err != nil {
-				e.printfln("Error watching files: %v", err)
-			}
-			time.Sleep(time.Second * 2)
+	// the tasks run in the background, so a change can cancel them and run
+	// them again, even if they never finish by themselves, like a server.
+	// Like when watching stops, the running commands are sent SIGTERM and
+	// killed after the grace period.
+	var (
+		stopRunCtx context.CancelFunc
+		cancelRun  context.CancelFunc
+		runDone    chan struct{}
+	)
+	startRun := func() {
+		stopCtx, stop := context.WithCancel(stopContext(ctx))
+		runCtx, cancel := withGracePeriod(stopCtx, e.killContext(), e.GracePeriod)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			e.runWatchedTasks(runCtx, args)
+		}()
+		stopRunCtx, cancelRun, runDone = stop, cancel, done
+	}
+	stopRun := func() {
+		select {
+		case <-runDone:
+		default:
+			e.println("task: Stopping the tasks to run them again")
+			stopRunCtx()
+			<-runDone
 		}
-	}()
+		cancelRun()
+	}
+
+	// run tasks on init
+	startRun()
+	if _, err := e.registerWatchedFiles(watcher, args); err != nil {
+		e.printfln("Error watching files: %v", err)
+	}
+
+	// debounce is only set while changes are waiting to be handled
+	var debounce <-chan time.Time
 
-loop:
 	for {
 		select {
-		case <-watcher.Events:
-			for _, a := range args {
-				if err := e.RunTask(context.Background(), a); err != nil {
-					e.println(err)
-					continue loop
+		case event := <-watcher.Events:
+			if _, ok := e.watchingFiles[filepath.Clean(event.Name)]; ok {
+				debounce = time.After(watchDebounce)
+				continue
+			}
+			// a new file or directory may be a source, or contain some
+			if event.Op&fsnotify.Create != 0 {
+				newFiles, err := e.registerWatchedFiles(watcher, args)
+				if err != nil {
+					e.printfln("Error watching files: %v", err)
 				}
+				if newFiles {
+					debounce = time.After(watchDebounce)
+				}
+			}
+		case <-debounce:
+			debounce = nil
+			stopRun()
+			startRun()
+			if _, err := e.registerWatchedFiles(watcher, args); err != nil {
+				e.printfln("Error watching files: %v", err)
 			}
 		case err := <-watcher.Errors:
 			e.println(err)
-			continue loop
+		case <-stopContext(ctx).Done():
+			// the running commands are given the grace period to exit
+			<-runDone
+			stopRunCtx()
+			cancelRun()
+			e.println("task: Stopped watching")
+			return nil
 		}
 	}
 }
 
-func (e *Executor) registerWatchedFiles(w *fsnotify.Watcher, args []string) error {
-	oldWatchingFiles := e.watchingFiles
-	e.watchingFiles = make(map[string]struct{}, len(oldWatchingFiles))
-
-	for k := range oldWatchingFiles {
-		if err := w.Remove(k); err != nil {
-			return err
+// runWatchedTasks runs the given tasks from scratch, printing the error of
+// the first one to fail unless they were canceled
+func (e *Executor) runWatchedTasks(ctx context.Context, args []string) {
+	e.resetRunState()
+	for _, a := range args {
+		if _, err := e.runTaskOnce(ctx, Call{Task: a}); err != nil {
+			if ctx.Err() == nil && stopContext(ctx).Err() == nil {
+				e.println(err)
+			}
+			return
 		}
 	}
+}
 
+// registerWatchedFiles makes w watch the directories where the sources of the
+// given tasks, of their dependencies and of the tasks they call are, and only
+// them. It tells if there are sources that weren't there before.
+func (e *Executor) registerWatchedFiles(w *fsnotify.Watcher, args []string) (newFiles bool, err error) {
+	var (
+		files   = make(map[string]struct{})
+		dirs    = make(map[string]struct{})
+		visited = make(map[string]bool)
+	)
 	for _, a := range args {
-		task, ok := e.Tasks[a]
-		if !ok {
-			return &taskNotFoundError{a}
+		if err := e.collectSources(Call{Task: a}, files, dirs, visited); err != nil {
+			return false, err
+		}
+	}
+
+	for d := range e.watchingDirs {
+		if _, ok := dirs[d]; !ok {
+			// the directory may already be gone, and then it's not watched anymore
+			_ = w.Remove(d)
+			delete(e.watchingDirs, d)
+		}
+	}
+	if e.watchingDirs == nil {
+		e.watchingDirs = make(map[string]struct{}, len(dirs))
+	}
+	for d := range dirs {
+		if _, ok := e.watchingDirs[d]; ok {
+			continue
+		}
+		if err := w.Add(d); err != nil {
+			return false, err
+		}
+		e.watchingDirs[d] = struct{}{}
+	}
+
+	for f := range files {
+		if _, ok := e.watchingFiles[f]; !ok {
+			newFiles = true
+		}
+	}
+	e.watchingFiles = files
+	return newFiles, nil
+}
+
+// collectSources adds to files the sources of the task of call and of the
+// tasks it runs, resolved like when checking if they are up to date, and to
+// dirs the directories to watch to know when they change
+func (e *Executor) collectSources(call Call, files, dirs map[string]struct{}, visited map[string]bool) error {
+	key := call.key()
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+
+	t, ok := e.Tasks[call.Task]
+	if !ok {
+		return &TaskNotFoundError{call.Task}
+	}
+
+	for _, d := range t.Deps {
+		depCall, err := e.getCall(call, d.Task, d.Vars)
+		if err != nil {
+			return err
 		}
-		if err := e.registerWatchedFiles(w, task.Deps); err != nil {
+		if err := e.collectSources(depCall, files, dirs, visited); err != nil {
 			return err
 		}
-		for _, s := range task.Sources {
-			files, err := zglob.Glob(s)
-			if err != nil {
-				return err
-			}
-			for _, f := range files {
-				if err := w.Add(f); err != nil {
-					return err
-				}
-				e.watchingFiles[f] = struct{}{}
+	}
+	for _, c := range t.allCmds() {
+		if c.Task == "" {
+			continue
+		}
+		cmdCall, err := e.getCall(call, c.Task, c.Vars)
+		if err != nil {
+			return err
+		}
+		if err := e.collectSources(cmdCall, files, dirs, visited); err != nil {
+			return err
+		}
+	}
 
-				// run if is new file
-				if oldWatchingFiles != nil {
-					if _, ok := oldWatchingFiles[f]; !ok {
-						w.Events <- fsnotify.Event{Name: f, Op: fsnotify.Create}
-					}
-				}
-			}
+	dir, err := e.getTaskDir(call)
+	if err != nil {
+		return err
+	}
+	sources, err := e.ReplaceSliceVariables(call, t.Sources)
+	if err != nil {
+		return err
+	}
+	include, exclude := splitPatterns(dir, sources)
+	for _, p := range include {
+		matches, err := globPattern(p, exclude)
+		// sources that don't exist yet may be created later
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, f := range matches {
+			files[filepath.Clean(f)] = struct{}{}
+		}
+
+		if err := addWatchedDirs(p, exclude, dirs); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+// addWatchedDirs adds to dirs the directories where the files matched by
+// pattern may be: the root of the pattern and, if the pattern goes deeper, all
+// the directories in it, except the excluded ones
+func addWatchedDirs(pattern string, exclude []string, dirs map[string]struct{}) error {
+	root := globRoot(pattern)
+	rest := strings.TrimPrefix(filepath.ToSlash(pattern), filepath.ToSlash(root))
+	if !strings.Contains(strings.TrimPrefix(rest, "/"), "/") {
+		if _, err := os.Stat(root); err == nil {
+			dirs[root] = struct{}{}
+		}
+		return nil
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the root may not exist yet, or a directory be removed meanwhile
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && (info.Name() == ".git" || info.Name() == StateDirPath || isExcluded(path, exclude)) {
+			return filepath.SkipDir
+		}
+		dirs[path] = struct{}{}
+		return nil
+	})
+}